@@ -0,0 +1,1236 @@
+package album
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ugorji/go/codec"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	os.Exit(m.Run())
+}
+
+// newTestService returns a service backed by a fresh copy of the seed
+// catalog.
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	return NewService(newMemoryStore(SeedAlbums))
+}
+
+// doRequest sends a request to router and returns the recorded response.
+func doRequest(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetAlbums(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantIDs   []string
+		wantTotal int
+		wantNext  string
+	}{
+		{name: "all", query: "", wantCode: http.StatusOK, wantIDs: []string{"1", "2", "3"}, wantTotal: 3},
+		{name: "artist is case-insensitive", query: "?artist=gERRY+mulligan", wantCode: http.StatusOK, wantIDs: []string{"2"}, wantTotal: 1},
+		{name: "price range", query: "?min_price=20&max_price=50", wantCode: http.StatusOK, wantIDs: []string{"3"}, wantTotal: 1},
+		{name: "sort by price", query: "?sort=price", wantCode: http.StatusOK, wantIDs: []string{"2", "3", "1"}, wantTotal: 3},
+		{name: "sort by title descending", query: "?sort=-title", wantCode: http.StatusOK, wantIDs: []string{"3", "2", "1"}, wantTotal: 3},
+		{name: "first page", query: "?limit=2", wantCode: http.StatusOK, wantIDs: []string{"1", "2"}, wantTotal: 3, wantNext: "/albums?limit=2&offset=2"},
+		{name: "last page", query: "?limit=2&offset=2", wantCode: http.StatusOK, wantIDs: []string{"3"}, wantTotal: 3},
+		{name: "offset past end", query: "?offset=10", wantCode: http.StatusOK, wantIDs: []string{}, wantTotal: 3},
+		{name: "bad sort key", query: "?sort=artist", wantCode: http.StatusBadRequest},
+		{name: "negative min price", query: "?min_price=-1", wantCode: http.StatusBadRequest},
+		{name: "huge max price", query: "?max_price=1e15", wantCode: http.StatusOK, wantIDs: []string{"1", "2", "3"}, wantTotal: 3},
+		{name: "min price out of range", query: "?min_price=1e300", wantCode: http.StatusBadRequest},
+		{name: "max price out of range", query: "?max_price=1e19", wantCode: http.StatusBadRequest},
+		{name: "non-numeric limit", query: "?limit=ten", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestService(t).Router()
+			w := doRequest(router, http.MethodGet, "/albums"+tt.query, "")
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				var e errorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil || e.Error != "INVALID_INPUT" {
+					t.Fatalf("error body = %s, want INVALID_INPUT envelope", w.Body)
+				}
+				return
+			}
+
+			var page albumPage
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if page.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", page.Total, tt.wantTotal)
+			}
+			if page.Next != tt.wantNext {
+				t.Errorf("next = %q, want %q", page.Next, tt.wantNext)
+			}
+			var ids []string
+			for _, a := range page.Albums {
+				ids = append(ids, a.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGetAlbumByID(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		ifNoneMatch string
+		wantCode    int
+		wantTitle   string
+	}{
+		{name: "found", id: "2", wantCode: http.StatusOK, wantTitle: "Jeru"},
+		{name: "missing", id: "42", wantCode: http.StatusNotFound},
+		{name: "current etag", id: "1", ifNoneMatch: `"1"`, wantCode: http.StatusNotModified},
+		{name: "stale etag", id: "1", ifNoneMatch: `"7"`, wantCode: http.StatusOK, wantTitle: "Blue Train"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestService(t).Router()
+			req := httptest.NewRequest(http.MethodGet, "/albums/"+tt.id, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantTitle == "" {
+				return
+			}
+			var a Album
+			if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if a.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", a.Title, tt.wantTitle)
+			}
+			if got := w.Header().Get("ETag"); got != etag(a) {
+				t.Errorf("ETag = %q, want %q", got, etag(a))
+			}
+		})
+	}
+}
+
+func TestUpdateAlbum(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		ifMatch    string
+		body       string
+		wantCode   int
+		wantTitle  string
+		wantArtist string
+	}{
+		{name: "put", method: http.MethodPut, ifMatch: `"1"`, body: `{"id":"1","title":"Giant Steps","artist":"John Coltrane","price":19.99}`, wantCode: http.StatusOK, wantTitle: "Giant Steps", wantArtist: "John Coltrane"},
+		{name: "put takes the id from the path", method: http.MethodPut, ifMatch: `"1"`, body: `{"title":"Giant Steps","artist":"John Coltrane","price":19.99}`, wantCode: http.StatusOK, wantTitle: "Giant Steps", wantArtist: "John Coltrane"},
+		{name: "put matching any version", method: http.MethodPut, ifMatch: "*", body: `{"title":"Giant Steps","price":19.99}`, wantCode: http.StatusOK, wantTitle: "Giant Steps"},
+		{name: "patch keeps other fields", method: http.MethodPatch, ifMatch: `"1"`, body: `{"title":"Lush Life"}`, wantCode: http.StatusOK, wantTitle: "Lush Life", wantArtist: "John Coltrane"},
+		{name: "patch with gzip etag", method: http.MethodPatch, ifMatch: `"1-gzip"`, body: `{"title":"Lush Life"}`, wantCode: http.StatusOK, wantTitle: "Lush Life", wantArtist: "John Coltrane"},
+		{name: "put without if-match", method: http.MethodPut, body: `{"title":"Giant Steps","price":19.99}`, wantCode: http.StatusPreconditionRequired},
+		{name: "patch without if-match", method: http.MethodPatch, body: `{"title":"Lush Life"}`, wantCode: http.StatusPreconditionRequired},
+		{name: "put stale version", method: http.MethodPut, ifMatch: `"7"`, body: `{"title":"Giant Steps","price":19.99}`, wantCode: http.StatusPreconditionFailed},
+		{name: "patch stale version", method: http.MethodPatch, ifMatch: `"7"`, body: `{"title":"Lush Life"}`, wantCode: http.StatusPreconditionFailed},
+		{name: "unparsable if-match", method: http.MethodPatch, ifMatch: "soon", body: `{"title":"Lush Life"}`, wantCode: http.StatusPreconditionFailed},
+		{name: "put id mismatch", method: http.MethodPut, ifMatch: `"1"`, body: `{"id":"2","title":"Giant Steps","price":19.99}`, wantCode: http.StatusBadRequest},
+		{name: "patch id mismatch", method: http.MethodPatch, ifMatch: `"1"`, body: `{"id":"2"}`, wantCode: http.StatusBadRequest},
+		{name: "patch blanks a required field", method: http.MethodPatch, ifMatch: `"1"`, body: `{"title":""}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestService(t).Router()
+			req := httptest.NewRequest(tt.method, "/albums/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+			get := doRequest(router, http.MethodGet, "/albums/1", "")
+			if tt.wantCode != http.StatusOK {
+				if got := get.Header().Get("ETag"); got != `"1"` {
+					t.Errorf("ETag after rejected update = %s, want \"1\"", got)
+				}
+				return
+			}
+
+			var a Album
+			if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if a.ID != "1" || a.Title != tt.wantTitle || a.Artist != tt.wantArtist {
+				t.Errorf("album = %+v, want id 1, title %q, artist %q", a, tt.wantTitle, tt.wantArtist)
+			}
+			// The update bumps the version, so the old ETag goes stale.
+			if got := w.Header().Get("ETag"); got != `"2"` {
+				t.Errorf("ETag = %s, want \"2\"", got)
+			}
+			if got := get.Header().Get("ETag"); got != `"2"` {
+				t.Errorf("ETag of a later GET = %s, want \"2\"", got)
+			}
+		})
+	}
+}
+
+func TestPostAlbums(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   int
+		wantID     string
+		wantFields []string
+	}{
+		{name: "valid", body: `{"title":"Kind of Blue","artist":"Miles Davis","price":24.99}`, wantCode: http.StatusCreated, wantID: "4"},
+		{name: "client id is ignored", body: `{"id":"1","title":"Giant Steps","price":19.99}`, wantCode: http.StatusCreated, wantID: "4"},
+		{name: "free album", body: `{"title":"Demo","price":0}`, wantCode: http.StatusCreated, wantID: "4"},
+		{name: "missing title", body: `{"artist":"Nobody","price":1}`, wantCode: http.StatusBadRequest, wantFields: []string{"title"}},
+		{name: "negative price", body: `{"title":"Refund","price":-5}`, wantCode: http.StatusBadRequest, wantFields: []string{"price"}},
+		{name: "price out of range", body: `{"title":"Priceless","price":92233720368547758.07}`, wantCode: http.StatusBadRequest},
+		{name: "malformed json", body: `{"title":`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			router := svc.Router()
+			w := doRequest(router, http.MethodPost, "/albums", tt.body)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+
+			if tt.wantCode != http.StatusCreated {
+				var e errorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatalf("decoding error body: %v", err)
+				}
+				var fields []string
+				for _, f := range e.Fields {
+					fields = append(fields, f.Field)
+				}
+				if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+					t.Errorf("fields = %v, want %v", fields, tt.wantFields)
+				}
+				return
+			}
+
+			var a Album
+			if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			if a.ID != tt.wantID {
+				t.Errorf("id = %q, want %q", a.ID, tt.wantID)
+			}
+			if got := w.Header().Get("Location"); got != "/albums/"+tt.wantID {
+				t.Errorf("Location = %q, want /albums/%s", got, tt.wantID)
+			}
+			if _, err := svc.store.Get(tt.wantID); err != nil {
+				t.Errorf("album %s not stored: %v", tt.wantID, err)
+			}
+		})
+	}
+}
+
+// TestConcurrentReadsAndWrites hammers the router from many goroutines
+// at once. Run with -race to check the store's locking.
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	svc := newTestService(t)
+	router := svc.Router()
+
+	const writers, readers, perGoroutine = 10, 10, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				body := `{"title":"Album ` + strconv.Itoa(w*perGoroutine+i) + `","price":9.99}`
+				if rec := doRequest(router, http.MethodPost, "/albums", body); rec.Code != http.StatusCreated {
+					t.Errorf("POST status = %d", rec.Code)
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				doRequest(router, http.MethodGet, "/albums?limit=5", "")
+				doRequest(router, http.MethodGet, "/albums/"+strconv.Itoa(i%3+1), "")
+			}
+		}()
+	}
+	wg.Wait()
+
+	list, err := svc.store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(SeedAlbums) + writers*perGoroutine; len(list) != want {
+		t.Fatalf("store has %d albums, want %d", len(list), want)
+	}
+
+	// Every generated ID must be unique.
+	seen := make(map[string]bool, len(list))
+	for _, a := range list {
+		if seen[a.ID] {
+			t.Fatalf("duplicate id %s", a.ID)
+		}
+		seen[a.ID] = true
+	}
+}
+
+func TestSearchAlbums(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{name: "title token", query: "?q=train", wantCode: http.StatusOK, wantIDs: []string{"1"}},
+		{name: "case-insensitive artist", query: "?q=COLTRANE", wantCode: http.StatusOK, wantIDs: []string{"1"}},
+		{name: "title outranks artist", query: "?q=sarah+brown", wantCode: http.StatusOK, wantIDs: []string{"3"}},
+		{name: "any token matches", query: "?q=jeru+blue", wantCode: http.StatusOK, wantIDs: []string{"1", "2"}},
+		{name: "no match", query: "?q=zeppelin", wantCode: http.StatusOK, wantIDs: nil},
+		{name: "missing q", query: "", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestService(t).Router()
+			w := doRequest(router, http.MethodGet, "/albums/search"+tt.query, "")
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var res searchResults
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			var ids []string
+			for _, h := range res.Results {
+				ids = append(ids, h.Album.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSearchIndexFollowsUpdates(t *testing.T) {
+	s := newMemoryStore(SeedAlbums)
+	if _, err := s.Update(Album{ID: "2", Title: "Night Train", Artist: "Oscar Peterson"}, anyVersion, "tester"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("1", anyVersion, "tester"); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _ := s.Search("train", 10)
+	if len(hits) != 1 || hits[0].Album.ID != "2" {
+		t.Fatalf("search train = %+v, want only album 2", hits)
+	}
+	if hits, _ := s.Search("jeru", 10); len(hits) != 0 {
+		t.Fatalf("search jeru = %+v, want no hits after rename", hits)
+	}
+}
+
+func TestWriteRoutesRequireAuth(t *testing.T) {
+	secret := []byte("test-secret")
+	sign := func(t *testing.T, key []byte, scope string, exp time.Time) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
+			Scope: scope,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "ci",
+				ExpiresAt: jwt.NewNumericDate(exp),
+			},
+		}).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		header   string
+		value    string
+		body     string
+		wantCode int
+	}{
+		{name: "reads stay public", method: http.MethodGet, target: "/albums/1", wantCode: http.StatusOK},
+		{name: "no credentials", method: http.MethodPost, target: "/albums", wantCode: http.StatusUnauthorized},
+		{name: "unknown api key", method: http.MethodPost, target: "/albums", header: "X-API-Key", value: "nope", wantCode: http.StatusUnauthorized},
+		{name: "api key with scope", method: http.MethodPost, target: "/albums", header: "X-API-Key", value: "writer-key", wantCode: http.StatusCreated},
+		{name: "api key without scope", method: http.MethodDelete, target: "/albums/1", header: "X-API-Key", value: "writer-key", wantCode: http.StatusForbidden},
+		{name: "jwt with scope", method: http.MethodPost, target: "/albums", header: "Authorization", value: sign(t, secret, "albums:read albums:write", later), wantCode: http.StatusCreated},
+		{name: "jwt without scope", method: http.MethodPost, target: "/albums", header: "Authorization", value: sign(t, secret, "albums:read", later), wantCode: http.StatusForbidden},
+		{name: "expired jwt", method: http.MethodPost, target: "/albums", header: "Authorization", value: sign(t, secret, scopeWrite, time.Now().Add(-time.Minute)), wantCode: http.StatusUnauthorized},
+		{name: "jwt signed with another key", method: http.MethodPost, target: "/albums", header: "Authorization", value: sign(t, []byte("other"), scopeWrite, later), wantCode: http.StatusUnauthorized},
+		{name: "invalid body without credentials", method: http.MethodPost, target: "/albums", body: `{"title":5}`, wantCode: http.StatusUnauthorized},
+		{name: "invalid body with credentials", method: http.MethodPost, target: "/albums", header: "X-API-Key", value: "writer-key", body: `{"title":5}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			router := svc.Router()
+			svc.auth = &authenticator{
+				keys:      []apiKey{{Name: "seeder", Key: "writer-key", Scopes: []string{scopeWrite}}},
+				jwtSecret: secret,
+			}
+
+			body := tt.body
+			if body == "" {
+				body = `{"title":"Signed","price":1}`
+			}
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", "*")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := newRateLimiter(rateLimit{Rate: 2, Burst: 2})
+	now := time.Unix(0, 0)
+
+	for i, want := range []bool{true, true, false} {
+		if d := l.take("ip:1", now); d.allowed != want {
+			t.Fatalf("take %d allowed = %v, want %v", i, d.allowed, want)
+		}
+	}
+	if d := l.take("ip:1", now); d.retryAfter != 500*time.Millisecond {
+		t.Fatalf("retryAfter = %v, want 500ms", d.retryAfter)
+	}
+	if d := l.take("ip:2", now); !d.allowed {
+		t.Fatal("a different client shares the first one's bucket")
+	}
+	if d := l.take("ip:1", now.Add(500*time.Millisecond)); !d.allowed || d.remaining != 0 {
+		t.Fatalf("after refill got %+v, want allowed with 0 remaining", d)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	svc := newTestService(t)
+	router := svc.Router()
+	svc.limits = newRateLimits(rateLimitConfig{
+		Default: rateLimit{Rate: 100, Burst: 100},
+		Routes:  map[string]rateLimit{"GET /albums/:id": {Rate: 0.001, Burst: 1}},
+	})
+
+	w := doRequest(router, http.MethodGet, "/albums/1", "")
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: status %d, headers %v", w.Code, w.Header())
+	}
+
+	w = doRequest(router, http.MethodGet, "/albums/2", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After")
+	}
+
+	// Other routes have their own, larger buckets.
+	if w := doRequest(router, http.MethodGet, "/albums", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET /albums status = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		wantSecond     int
+		wantClientIP   string
+	}{
+		{"no trusted proxies", nil, http.StatusTooManyRequests, "192.0.2.1"},
+		{"request from a trusted proxy", []string{"192.0.2.0/24"}, http.StatusOK, "203.0.113.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t)
+			var logs bytes.Buffer
+			svc.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+			svc.trustedProxies = tt.trustedProxies
+			svc.limits = newRateLimits(rateLimitConfig{Default: rateLimit{Rate: 0.001, Burst: 1}})
+			router := svc.Router()
+
+			// httptest requests all come from 192.0.2.1; each claims to
+			// be forwarded for a different client.
+			var w *httptest.ResponseRecorder
+			for _, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+				logs.Reset()
+				req := httptest.NewRequest(http.MethodGet, "/albums", nil)
+				req.Header.Set("X-Forwarded-For", ip)
+				w = httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+			}
+			if w.Code != tt.wantSecond {
+				t.Fatalf("second request status = %d, want %d", w.Code, tt.wantSecond)
+			}
+
+			var record struct {
+				ClientIP string `json:"client_ip"`
+			}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("log line %q: %v", logs.String(), err)
+			}
+			if record.ClientIP != tt.wantClientIP {
+				t.Errorf("logged client_ip = %q, want %q", record.ClientIP, tt.wantClientIP)
+			}
+		})
+	}
+}
+
+func TestBroadcasterResumesFromBuffer(t *testing.T) {
+	b := newBroadcaster(2)
+	for _, id := range []string{"1", "2", "3"} {
+		b.publish(eventCreated, Album{ID: id})
+	}
+
+	// Only the last two events fit in the buffer.
+	backlog, ch, cancel := b.subscribe(0)
+	defer cancel()
+	if len(backlog) != 2 || backlog[0].ID != 2 || backlog[1].ID != 3 {
+		t.Fatalf("backlog = %+v, want events 2 and 3", backlog)
+	}
+	if resumed, _, cancel := b.subscribe(2); len(resumed) != 1 || resumed[0].Album.ID != "3" {
+		t.Fatalf("resume after 2 = %+v, want only event 3", resumed)
+	} else {
+		cancel()
+	}
+
+	b.publish(eventDeleted, Album{ID: "1"})
+	if e := <-ch; e.ID != 4 || e.Type != eventDeleted {
+		t.Fatalf("live event = %+v, want deleted event 4", e)
+	}
+
+	b.close()
+	if _, ok := <-ch; ok {
+		t.Fatal("subscription still open after close")
+	}
+}
+
+func TestBroadcasterResetsAfterOverrun(t *testing.T) {
+	b := newBroadcaster(2)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		b.publish(eventCreated, Album{ID: id})
+	}
+
+	tests := []struct {
+		lastID  uint64
+		wantIDs []uint64
+		reset   bool
+	}{
+		{lastID: 1, wantIDs: []uint64{2, 3, 4}, reset: true},
+		{lastID: 2, wantIDs: []uint64{3, 4}},
+		{lastID: 4, wantIDs: nil},
+		// An ID this broadcaster never issued, e.g. from before a restart.
+		{lastID: 9, wantIDs: []uint64{2, 3, 4}, reset: true},
+	}
+	for _, tt := range tests {
+		backlog, _, cancel := b.subscribe(tt.lastID)
+		cancel()
+		var ids []uint64
+		for _, e := range backlog {
+			ids = append(ids, e.ID)
+		}
+		if !slices.Equal(ids, tt.wantIDs) {
+			t.Errorf("resume after %d: event IDs %v, want %v", tt.lastID, ids, tt.wantIDs)
+			continue
+		}
+		if reset := len(backlog) > 0 && backlog[0].Type == eventReset; reset != tt.reset {
+			t.Errorf("resume after %d: reset = %v, want %v", tt.lastID, reset, tt.reset)
+		}
+	}
+
+	// Over HTTP the reset comes first and carries no album. Closing the
+	// broadcaster ends the stream once the backlog is written.
+	svc := newTestService(t)
+	svc.events = newBroadcaster(2)
+	router := svc.Router()
+	for range 4 {
+		svc.events.publish(eventUpdated, SeedAlbums[0])
+	}
+	svc.events.close()
+	req := httptest.NewRequest(http.MethodGet, "/albums/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.HasPrefix(w.Body.String(), "id: 2\nevent: reset\ndata: {\"type\":\"reset\",\"time\":") {
+		t.Errorf("stream = %q, want it to start with a reset event", w.Body)
+	}
+}
+
+func TestMoneyRoundTrip(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     money
+		wantOut  string
+		wantErr  bool
+	}{
+		{in: "17.99", currency: "USD", want: 1799, wantOut: "17.99"},
+		{in: "0.1", currency: "USD", want: 10, wantOut: "0.10"},
+		{in: "1.799e1", currency: "EUR", want: 1799, wantOut: "17.99"},
+		{in: "1800", currency: "JPY", want: 1800, wantOut: "1800"},
+		{in: "1.234", currency: "KWD", want: 1234, wantOut: "1.234"},
+		{in: "1.999", currency: "USD", wantErr: true},
+		{in: "12.5", currency: "JPY", wantErr: true},
+		{in: "1", currency: "XYZ", wantErr: true},
+		{in: "abc", currency: "USD", wantErr: true},
+		{in: "9223372036854775.807", currency: "KWD", want: math.MaxInt64, wantOut: "9223372036854775.807"},
+		{in: "922337203685477.58", currency: "USD", want: 92233720368547758, wantOut: "922337203685477.58"},
+		{in: "92233720368547758.07", currency: "USD", wantErr: true},
+		{in: "1e30", currency: "JPY", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in+" "+tt.currency, func(t *testing.T) {
+			got, err := parseMoney(tt.in, tt.currency)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseMoney = %d, want error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("parseMoney = %d, %v; want %d", got, err, tt.want)
+			}
+			if out := got.format(tt.currency); out != tt.wantOut {
+				t.Errorf("format = %q, want %q", out, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestAlbumPriceJSONStaysNumeric(t *testing.T) {
+	// Summing as floats would drift; as minor units it's exact.
+	var total money
+	for i := 0; i < 10; i++ {
+		var a Album
+		if err := json.Unmarshal([]byte(`{"title":"Dime","price":0.1}`), &a); err != nil {
+			t.Fatal(err)
+		}
+		total += a.Price
+	}
+	if total != 100 {
+		t.Fatalf("ten 0.10 prices sum to %d cents, want 100", total)
+	}
+
+	data, err := json.Marshal(SeedAlbums[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"2","title":"Jeru","artist":"Gerry Mulligan","price":17.99,"currency":"USD","version":0}`
+	if string(data) != want {
+		t.Fatalf("json = %s, want %s", data, want)
+	}
+}
+
+func TestRequestIDAndLogging(t *testing.T) {
+	svc := newTestService(t)
+	var logs bytes.Buffer
+	svc.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	router := svc.Router()
+
+	// A caller's ID is kept, and shows up in the error body and log line.
+	req := httptest.NewRequest(http.MethodGet, "/albums/404", nil)
+	req.Header.Set(requestIDHeader, "trace-abc")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if got := w.Header().Get(requestIDHeader); got != "trace-abc" {
+		t.Fatalf("X-Request-ID = %q, want trace-abc", got)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RequestID != "trace-abc" {
+		t.Errorf("error body request_id = %q, want trace-abc", body.RequestID)
+	}
+
+	var record struct {
+		Level     string `json:"level"`
+		RequestID string `json:"request_id"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("log line %q: %v", logs.String(), err)
+	}
+	if record.Level != "WARN" || record.RequestID != "trace-abc" || record.Route != "/albums/:id" || record.Status != http.StatusNotFound {
+		t.Errorf("log record = %+v", record)
+	}
+
+	// Without one, or with an unusable one, an ID is generated.
+	w = doRequest(router, http.MethodGet, "/albums", "")
+	if got := w.Header().Get(requestIDHeader); len(got) != 32 {
+		t.Errorf("generated X-Request-ID = %q, want 32 hex chars", got)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/albums", nil)
+	req.Header.Set(requestIDHeader, "has spaces")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if got := w.Header().Get(requestIDHeader); got == "has spaces" {
+		t.Errorf("X-Request-ID %q was propagated, want a generated one", got)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	router := newTestService(t).Router()
+	send := func(method, target, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(http.MethodDelete, "/albums/2", `"1"`); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if w := send(http.MethodGet, "/albums/2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted album status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	var page albumPage
+	json.Unmarshal(send(http.MethodGet, "/albums", "").Body.Bytes(), &page)
+	if page.Total != len(SeedAlbums)-1 {
+		t.Errorf("listing total = %d, want %d", page.Total, len(SeedAlbums)-1)
+	}
+
+	// The delete bumped the version, so restoring needs the new one.
+	if w := send(http.MethodPost, "/albums/2/restore", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("restore at stale version status = %d, want %d", w.Code, http.StatusPreconditionFailed)
+	}
+	w := send(http.MethodPost, "/albums/2/restore", `"2"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("restore status = %d, ETag %q, want 200 and \"3\"", w.Code, w.Header().Get("ETag"))
+	}
+	if w := send(http.MethodPost, "/albums/2/restore", "*"); w.Code != http.StatusConflict {
+		t.Errorf("restoring a live album status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	var history albumHistory
+	if err := json.Unmarshal(send(http.MethodGet, "/albums/2/history", "").Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range history.Entries {
+		actions = append(actions, e.Action+"@"+strconv.Itoa(e.Version)+" by "+e.Actor)
+	}
+	want := "created@1 by seed,deleted@2 by anonymous,restored@3 by anonymous"
+	if got := strings.Join(actions, ","); got != want || history.Deleted {
+		t.Errorf("history = %s (deleted %v), want %s", got, history.Deleted, want)
+	}
+	if del := history.Entries[1]; del.Before == nil || del.Before.Title != "Jeru" || del.After != nil {
+		t.Errorf("delete entry = %+v, want the album before and nothing after", del)
+	}
+}
+
+func TestFileStoreReplaysHistory(t *testing.T) {
+	path := t.TempDir() + "/albums.jsonl"
+	s, err := openFileStore(path, SeedAlbums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Update(Album{ID: "1", Title: "Blue Train (Remastered)", Artist: "John Coltrane"}, 1, "editor"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("3", anyVersion, "editor"); err != nil {
+		t.Fatal(err)
+	}
+	want, _, _ := s.History("1")
+	s.Close()
+
+	s, err = openFileStore(path, SeedAlbums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get("3"); err != errAlbumNotFound {
+		t.Errorf("Get deleted album after replay err = %v, want %v", err, errAlbumNotFound)
+	}
+	got, _, _ := s.History("1")
+	if len(got) != 2 || got[1].Actor != "editor" || !got[1].Time.Equal(want[1].Time) || got[1].Before.Title != "Blue Train" {
+		t.Errorf("replayed history = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileStoreReplaysLegacyLog(t *testing.T) {
+	// Before deletes were soft, a log could delete an album and then add
+	// its ID again, starting the new album over at version 1.
+	path := t.TempDir() + "/albums.jsonl"
+	legacy := `{"op":"add","album":{"id":"7","title":"First","price":1}}
+{"op":"delete","album":{"id":"7"},"version":1}
+{"op":"add","album":{"id":"7","title":"Second","price":2}}
+{"op":"update","album":{"id":"7","title":"Second (Deluxe)","price":3},"version":1}
+`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openFileStore(path, SeedAlbums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	a, err := s.Get("7")
+	if err != nil || a.Title != "Second (Deluxe)" {
+		t.Fatalf("Get(7) = %+v, %v, want the updated second album", a, err)
+	}
+	history, deleted, _ := s.History("7")
+	var actions []string
+	for _, e := range history {
+		actions = append(actions, e.Action)
+	}
+	if got := strings.Join(actions, ","); got != "created,deleted,restored,updated,updated" || deleted {
+		t.Errorf("history = %s (deleted %v), want created,deleted,restored,updated,updated", got, deleted)
+	}
+	if _, err := s.Get("1"); err != errAlbumNotFound {
+		t.Errorf("a non-empty legacy log was seeded: Get(1) err = %v", err)
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router := newTestService(t).Router()
+	routes := make(map[string]bool)
+	for _, r := range router.Routes() {
+		if !strings.HasPrefix(r.Path, "/albums") {
+			continue
+		}
+		routes[r.Method+" "+r.Path] = true
+
+		// Custom methods are listed one path per action instead.
+		if r.Path == "/albums:action" {
+			continue
+		}
+		path := regexp.MustCompile(`/:(\w+)`).ReplaceAllString(r.Path, "/{$1}")
+		if item := apiSpec.Paths.Value(path); item == nil || item.GetOperation(r.Method) == nil {
+			t.Errorf("%s %s has no operation in openapi.json", r.Method, path)
+		}
+	}
+
+	for path, item := range apiSpec.Paths.Map() {
+		route := regexp.MustCompile(`\{(\w+)\}`).ReplaceAllString(path, ":$1")
+		if strings.HasPrefix(route, "/albums:") {
+			route = "/albums:action"
+		}
+		for method := range item.Operations() {
+			if !routes[method+" "+route] {
+				t.Errorf("openapi.json describes %s %s, which has no route", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	// Album is sent in its wire form.
+	types := map[string]any{
+		"Album":         albumJSON{},
+		"AlbumPatch":    albumPatch{},
+		"AlbumPage":     albumPage{},
+		"SearchResults": searchResults{},
+		"BulkResult":    bulkResult{},
+		"AlbumHistory":  albumHistory{},
+		"Error":         errorResponse{},
+	}
+	for name, ref := range apiSpec.Components.Schemas {
+		v, ok := types[name]
+		if !ok {
+			t.Errorf("schema %s has no Go type to check it against", name)
+			continue
+		}
+		checkSchema(t, name, ref.Value, reflect.TypeOf(v))
+	}
+}
+
+// checkSchema reports where schema, found at path in openapi.json,
+// disagrees with how typ encodes as JSON: a property with no matching
+// json tag or the other way round, or a property of the wrong type.
+func checkSchema(t *testing.T, path string, schema *openapi3.Schema, typ reflect.Type) {
+	t.Helper()
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(Album{}) {
+		typ = reflect.TypeOf(albumJSON{})
+	}
+	// A nullable reference is written as a one-element allOf.
+	if len(schema.AllOf) == 1 {
+		schema = schema.AllOf[0].Value
+	}
+
+	var want string
+	switch {
+	case typ == reflect.TypeOf(json.Number("")):
+		want = openapi3.TypeNumber
+	case typ == reflect.TypeOf(time.Time{}):
+		want = openapi3.TypeString
+	case typ.Kind() == reflect.String:
+		want = openapi3.TypeString
+	case typ.Kind() == reflect.Int:
+		want = openapi3.TypeInteger
+	case typ.Kind() == reflect.Bool:
+		want = openapi3.TypeBoolean
+	case typ.Kind() == reflect.Slice:
+		want = openapi3.TypeArray
+	case typ.Kind() == reflect.Struct:
+		want = openapi3.TypeObject
+	default:
+		t.Fatalf("%s: no schema type for Go type %s", path, typ)
+	}
+	if !schema.Type.Is(want) {
+		t.Errorf("%s: schema type %v, want %s for Go type %s", path, schema.Type.Slice(), want, typ)
+		return
+	}
+
+	switch want {
+	case openapi3.TypeArray:
+		checkSchema(t, path+"[]", schema.Items.Value, typ.Elem())
+	case openapi3.TypeObject:
+		fields := make(map[string]reflect.Type)
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fields[name] = f.Type
+		}
+		for name, prop := range schema.Properties {
+			ft, ok := fields[name]
+			if !ok {
+				t.Errorf("%s.%s is in openapi.json but not in %s", path, name, typ)
+				continue
+			}
+			checkSchema(t, path+"."+name, prop.Value, ft)
+		}
+		for name := range fields {
+			if _, ok := schema.Properties[name]; !ok {
+				t.Errorf("%s.%s is in %s but not in openapi.json", path, name, typ)
+			}
+		}
+	}
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	router := newTestService(t).Router()
+
+	w := doRequest(router, http.MethodGet, "/openapi.json", "")
+	if w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("GET /openapi.json = %d, want 200 with a JSON body", w.Code)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		wantField string
+	}{
+		{name: "search needs q", method: http.MethodGet, target: "/albums/search", wantField: "q"},
+		{name: "unknown export format", method: http.MethodGet, target: "/albums/export?format=xml", wantField: "format"},
+		{name: "bad currency code", method: http.MethodPost, target: "/albums", body: `{"title":"Kind of Blue","price":1,"currency":"dollars"}`, wantField: "currency"},
+		{name: "patch with wrong type", method: http.MethodPatch, target: "/albums/1", body: `{"title":7}`, wantField: "title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(router, tt.method, tt.target, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+			var e errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			if len(e.Fields) != 1 || e.Fields[0].Field != tt.wantField {
+				t.Errorf("fields = %+v, want one for %s", e.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	svc := newTestService(t)
+	router := svc.Router()
+	get := func(target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/albums/2")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") || strings.Contains(w.Body.String(), "\n") {
+		t.Errorf("default response = %s %q, want compact JSON", ct, w.Body)
+	}
+	if w := get("/albums/2?pretty=1"); !strings.Contains(w.Body.String(), "\n    \"title\": \"Jeru\"") {
+		t.Errorf("pretty response = %q, want indented JSON", w.Body)
+	}
+
+	w = get("/albums/2", "Accept", "application/xml")
+	var x struct {
+		XMLName xml.Name
+		Title   string `xml:"title"`
+		Price   string `xml:"price"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &x); err != nil {
+		t.Fatalf("decoding XML %q: %v", w.Body, err)
+	}
+	if x.XMLName.Local != "album" || x.Title != "Jeru" || x.Price != "17.99" {
+		t.Errorf("XML album = %+v, want <album> Jeru at 17.99", x)
+	}
+
+	w = get("/albums/2", "Accept", "application/msgpack")
+	var m map[string]any
+	if err := codec.NewDecoderBytes(w.Body.Bytes(), msgpackHandle).Decode(&m); err != nil {
+		t.Fatalf("decoding MessagePack: %v", err)
+	}
+	if m["title"] != "Jeru" || m["price"] != 17.99 {
+		t.Errorf("MessagePack album = %v, want Jeru at 17.99", m)
+	}
+
+	if w := get("/albums/2", "Accept", "text/html"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("Accept text/html status = %d, want %d", w.Code, http.StatusNotAcceptable)
+	}
+
+	// Errors come back in the negotiated format too.
+	w = get("/albums/999", "Accept", "application/xml")
+	var xe struct {
+		XMLName xml.Name
+		Error   string `xml:"error"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &xe); err != nil || w.Code != http.StatusNotFound || xe.Error != "NOT_FOUND" {
+		t.Errorf("XML 404 = %d %q (err %v), want an XML NOT_FOUND error", w.Code, w.Body, err)
+	}
+
+	// A write the client can't accept the response to isn't made.
+	req := httptest.NewRequest(http.MethodPost, "/albums", strings.NewReader(`{"title":"Unseen","price":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("POST with Accept text/html status = %d, want %d", w.Code, http.StatusNotAcceptable)
+	}
+	if _, err := svc.store.Get("4"); err != errAlbumNotFound {
+		t.Errorf("POST with Accept text/html stored album 4 (err %v)", err)
+	}
+
+	// Small bodies go out as they are; a full catalog page is gzipped.
+	if w := get("/albums", "Accept-Encoding", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("small listing Content-Encoding = %q, want none", w.Header().Get("Content-Encoding"))
+	}
+	for i := 0; i < 50; i++ {
+		svc.store.Add(Album{Title: "Filler " + strconv.Itoa(i), Artist: "Session Band", Price: 999}, "tester")
+	}
+	w = get("/albums", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("large listing Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page albumPage
+	if err := json.NewDecoder(zr).Decode(&page); err != nil || page.Total != len(SeedAlbums)+50 {
+		t.Errorf("gunzipped page total = %d (err %v), want %d", page.Total, err, len(SeedAlbums)+50)
+	}
+
+	// A gzipped album gets its own entity tag, which still works as a
+	// validator for that album.
+	long, _ := svc.store.Add(Album{Title: strings.Repeat("Long ", 250), Price: 100}, "tester")
+	w = get("/albums/"+long.ID, "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") != `"1-gzip"` {
+		t.Fatalf("gzipped album ETag = %q, want \"1-gzip\"", w.Header().Get("ETag"))
+	}
+	if w := get("/albums/"+long.ID, "Accept-Encoding", "gzip", "If-None-Match", `"1-gzip"`); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match with the gzip ETag status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if w := get("/albums/"+long.ID, "If-None-Match", `"1"`); w.Code != http.StatusNotModified || w.Header().Get("ETag") != `"1"` {
+		t.Errorf("identity album = %d with ETag %q, want 304 and \"1\"", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestMetricsCountPanics(t *testing.T) {
+	router := newTestService(t).Router()
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	// metricLine returns the exposition line for a series, or "".
+	metricLine := func(series string) string {
+		body := doRequest(router, http.MethodGet, "/metrics", "").Body.String()
+		for _, line := range strings.Split(body, "\n") {
+			if strings.HasPrefix(line, series+" ") {
+				return line
+			}
+		}
+		return ""
+	}
+
+	if w := doRequest(router, http.MethodGet, "/panic", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("GET /panic = %d, want 500", w.Code)
+	}
+	if got := metricLine(`album_http_requests_total{method="GET",route="/panic",status="500"}`); !strings.HasSuffix(got, " 1") {
+		t.Errorf("panicking request counted as %q, want one 500", got)
+	}
+	// Only the /metrics request itself is still in flight.
+	if got := metricLine("album_http_requests_in_flight"); got != "album_http_requests_in_flight 1" {
+		t.Errorf("in-flight gauge = %q after the panic, want 1", got)
+	}
+}
+
+func TestBulkImportAndExport(t *testing.T) {
+	svc := newTestService(t)
+	router := svc.Router()
+	importBody := func(contentType, body string) bulkResult {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/albums:bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("import status = %d, want 200; body %s", w.Code, w.Body)
+		}
+		var res bulkResult
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	rowStatuses := func(res bulkResult) string {
+		var rows []string
+		for _, r := range res.Results {
+			rows = append(rows, strconv.Itoa(r.Row)+":"+strconv.Itoa(r.Status)+":"+r.ID)
+		}
+		return strings.Join(rows, " ")
+	}
+
+	res := importBody(mimeJSONL, `{"id":"10","title":"Kind of Blue","artist":"Miles Davis","price":24.99}
+
+{"title":"Giant Steps","price":19.99}
+{"id":"1","title":"Taken"}
+{"title":
+{"artist":"No title"}
+{"id":"9223372036854775807","title":"Too big"}
+`)
+	if want := "1:201:10 2:201:11 3:409:1 4:400: 5:400: 6:400:"; rowStatuses(res) != want || res.Created != 2 || res.Failed != 4 {
+		t.Errorf("JSONL import = %s (%d created, %d failed), want %s", rowStatuses(res), res.Created, res.Failed, want)
+	}
+	if f := res.Results[5].Error.Fields; len(f) != 1 || f[0].Field != "id" {
+		t.Errorf("oversized ID error fields = %+v, want one for id", f)
+	}
+
+	res = importBody(mimeCSV, "title,price,currency\nLive at Birdland,12.50,eur\nShort row\nCheap,abc,USD\n")
+	if want := "1:201:12 2:400: 3:400:"; rowStatuses(res) != want {
+		t.Errorf("CSV import = %s, want %s", rowStatuses(res), want)
+	}
+	if w := doRequest(router, http.MethodPost, "/albums", `{"title":"Next"}`); w.Header().Get("Location") != "/albums/13" {
+		t.Errorf("POST after import Location = %q, want /albums/13", w.Header().Get("Location"))
+	}
+
+	// Each export format imports back into an empty service unchanged.
+	want, _ := svc.store.List()
+	for _, format := range []string{"jsonl", "csv"} {
+		w := doRequest(router, http.MethodGet, "/albums/export?format="+format, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("export %s status = %d", format, w.Code)
+		}
+
+		restored := NewService(newMemoryStore(nil))
+		contentType := map[string]string{"jsonl": mimeJSONL, "csv": mimeCSV}[format]
+		req := httptest.NewRequest(http.MethodPost, "/albums:bulk", w.Body)
+		req.Header.Set("Content-Type", contentType)
+		restored.Router().ServeHTTP(httptest.NewRecorder(), req)
+
+		got, _ := restored.store.List()
+		if len(got) != len(want) {
+			t.Fatalf("%s round trip restored %d albums, want %d", format, len(got), len(want))
+		}
+		for i := range want {
+			g, w := got[i], want[i]
+			g.Version, w.Version = 0, 0
+			if g != w {
+				t.Errorf("%s round trip album %d = %+v, want %+v", format, i, g, w)
+			}
+		}
+	}
+}
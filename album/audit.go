@@ -0,0 +1,112 @@
+package album
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anonymousActor is who mutations are recorded as when auth is off.
+const anonymousActor = "anonymous"
+
+// seedActor is who the seed catalog is recorded as created by.
+const seedActor = "seed"
+
+// change says who made a store mutation and when.
+type change struct {
+	By string
+	At time.Time
+}
+
+// changeBy stamps a change by actor at the current time.
+func changeBy(actor string) change {
+	return change{By: actor, At: time.Now().UTC()}
+}
+
+// auditEntry is one mutation in an album's history. Action is one of
+// the album event types. Before is nil for a creation or restore of a
+// deleted album, and After is nil for a deletion.
+type auditEntry struct {
+	Version int       `json:"version" xml:"version"`
+	Action  string    `json:"action" xml:"action"`
+	Actor   string    `json:"actor" xml:"actor"`
+	Time    time.Time `json:"time" xml:"time"`
+	Before  *Album    `json:"before" xml:"before"`
+	After   *Album    `json:"after" xml:"after"`
+}
+
+// albumHistory is the body of GET /albums/:id/history.
+type albumHistory struct {
+	ID      string       `json:"id" xml:"id"`
+	Deleted bool         `json:"deleted" xml:"deleted"`
+	Entries []auditEntry `json:"entries" xml:"entries>entry"`
+}
+
+// actor names who is making the request: the authenticated principal,
+// or anonymousActor when auth is off.
+func actor(c *gin.Context) string {
+	if name := c.GetString(principalKey); name != "" {
+		return name
+	}
+	return anonymousActor
+}
+
+// getAlbumHistory responds with every recorded change to the album
+// named by the id parameter, oldest first. Deleted albums keep their
+// history.
+func (svc *Service) getAlbumHistory(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+
+	entries, deleted, err := svc.store.History(id)
+	if errors.Is(err, errAlbumNotFound) {
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Album not found")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to load album history")
+		return
+	}
+	render(c, format, http.StatusOK, albumHistory{ID: id, Deleted: deleted, Entries: entries})
+}
+
+// restoreAlbum brings back the deleted album named by the id parameter,
+// provided If-Match names the version it was deleted at.
+func (svc *Service) restoreAlbum(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	restored, err := svc.store.Restore(c.Param("id"), version, actor(c))
+	if errors.Is(err, errAlbumNotFound) {
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Album not found")
+		return
+	}
+	if errors.Is(err, errAlbumNotDeleted) {
+		sendError(c, http.StatusConflict, "CONFLICT", "Album is not deleted")
+		return
+	}
+	if errors.Is(err, errVersionMismatch) {
+		sendError(c, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "If-Match does not match the current album version")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to restore album")
+		return
+	}
+	svc.events.publish(eventRestored, restored)
+	setETag(c, restored)
+	render(c, format, http.StatusOK, restored)
+}
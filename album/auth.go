@@ -0,0 +1,148 @@
+package album
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Scopes checked by the mutating album routes.
+const (
+	scopeWrite  = "albums:write"
+	scopeDelete = "albums:delete"
+)
+
+// principalKey is the gin context key holding the authenticated
+// caller's name.
+const principalKey = "principal"
+
+// apiKey is one entry in the auth config file.
+type apiKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+// authConfig is the JSON auth config file. Either section may be
+// omitted to disable that kind of credential.
+type authConfig struct {
+	APIKeys []apiKey `json:"api_keys"`
+	JWT     struct {
+		Secret string `json:"secret"`
+		Issuer string `json:"issuer"`
+	} `json:"jwt"`
+}
+
+// authenticator checks X-API-Key headers and HMAC-signed Bearer JWTs.
+type authenticator struct {
+	keys      []apiKey
+	jwtSecret []byte
+	jwtIssuer string
+}
+
+// jwtClaims are the claims read from a Bearer token. Scope is a
+// space-separated list, as in OAuth 2.0 access tokens.
+type jwtClaims struct {
+	Scope string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// loadAuthenticator reads the auth config file at path.
+func loadAuthenticator(path string) (*authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read auth config: %w", err)
+	}
+	var cfg authConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse auth config: %w", err)
+	}
+	for _, k := range cfg.APIKeys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("auth config: api key %q has no key", k.Name)
+		}
+	}
+	return &authenticator{
+		keys:      cfg.APIKeys,
+		jwtSecret: []byte(cfg.JWT.Secret),
+		jwtIssuer: cfg.JWT.Issuer,
+	}, nil
+}
+
+// errUnauthenticated is returned when a request carries no usable
+// credentials.
+var errUnauthenticated = errors.New("missing or invalid credentials")
+
+// authenticate returns the caller's name and scopes from the request's
+// X-API-Key or Authorization: Bearer header.
+func (a *authenticator) authenticate(r *http.Request) (string, []string, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		// Compare against every key in constant time so response timing
+		// doesn't leak how much of a guess was right.
+		var match *apiKey
+		for i := range a.keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(a.keys[i].Key)) == 1 {
+				match = &a.keys[i]
+			}
+		}
+		if match == nil {
+			return "", nil, errUnauthenticated
+		}
+		return match.Name, match.Scopes, nil
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || len(a.jwtSecret) == 0 {
+		return "", nil, errUnauthenticated
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.jwtIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.jwtIssuer))
+	}
+	var claims jwtClaims
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), &claims, func(*jwt.Token) (any, error) {
+		return a.jwtSecret, nil
+	}, opts...)
+	if err != nil {
+		return "", nil, errUnauthenticated
+	}
+	return claims.Subject, strings.Fields(claims.Scope), nil
+}
+
+// requireScope rejects requests that don't authenticate with a
+// credential granting scope: 401 without valid credentials, 403 when
+// they lack the scope.
+func (svc *Service) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if svc.auth == nil {
+			c.Next()
+			return
+		}
+
+		name, scopes, err := svc.auth.authenticate(c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="albums"`)
+			sendError(c, http.StatusUnauthorized, "UNAUTHORIZED", "A valid X-API-Key or Bearer token is required")
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			sendError(c, http.StatusForbidden, "FORBIDDEN", "Credentials lack the "+scope+" scope")
+			return
+		}
+
+		c.Set(principalKey, name)
+		c.Next()
+	}
+}
@@ -0,0 +1,309 @@
+package album
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Content types accepted by POST /albums:bulk and produced by
+// GET /albums/export.
+const (
+	mimeJSONL = "application/x-ndjson"
+	mimeCSV   = "text/csv"
+)
+
+// csvHeader is the column order written by the CSV export. Imports
+// accept the same columns in any order.
+var csvHeader = []string{"id", "title", "artist", "price", "currency"}
+
+// maxImportedID bounds numeric IDs an import may keep. It leaves room
+// for the generated IDs that follow them.
+const maxImportedID = math.MaxInt / 2
+
+// bulkRowResult reports what happened to one imported row. Row counts
+// data rows from 1, not including a CSV header.
+type bulkRowResult struct {
+	Row    int            `json:"row" xml:"row"`
+	Status int            `json:"status" xml:"status"`
+	ID     string         `json:"id,omitempty" xml:"id,omitempty"`
+	Error  *errorResponse `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// bulkResult is the response body of POST /albums:bulk.
+type bulkResult struct {
+	Created int             `json:"created" xml:"created"`
+	Failed  int             `json:"failed" xml:"failed"`
+	Results []bulkRowResult `json:"results" xml:"results>result"`
+}
+
+// albumAction dispatches custom-method routes of the form
+// /albums:<action>. gin treats the colon as the start of a parameter,
+// so the action arrives with its colon in c.Param("action").
+func (svc *Service) albumAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":bulk":
+		svc.bulkImportAlbums(c)
+	default:
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Unknown album action")
+	}
+}
+
+// bulkImportAlbums adds every album in a JSON Lines or CSV request body,
+// chosen by Content-Type. Rows are imported independently: a bad row is
+// reported in the results and doesn't stop the rest.
+func (svc *Service) bulkImportAlbums(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	var res bulkResult
+	var err error
+	switch mediaType {
+	case mimeJSONL, "application/jsonl", "application/json-lines":
+		err = svc.importJSONL(c.Request.Body, actor(c), &res)
+	case mimeCSV:
+		err = svc.importCSV(c.Request.Body, actor(c), &res)
+	default:
+		sendError(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE",
+			"Content-Type must be "+mimeJSONL+" or "+mimeCSV)
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusBadRequest, "INVALID_INPUT", "Failed to read import: "+err.Error())
+		return
+	}
+	render(c, format, http.StatusOK, res)
+}
+
+// importJSONL imports one JSON album per non-blank line of r, as
+// created by the actor by.
+func (svc *Service) importJSONL(r io.Reader, by string, res *bulkResult) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row++
+
+		var a Album
+		if err := json.Unmarshal([]byte(line), &a); err != nil {
+			res.add(rowError(row, validationResponse(err, "Invalid JSON format", "Invalid album data")))
+			continue
+		}
+		res.add(svc.importAlbum(row, a, by))
+	}
+	return scanner.Err()
+}
+
+// importCSV imports one album per record of r after a header row naming
+// the columns, as created by the actor by.
+func (svc *Service) importCSV(r io.Reader, by string, res *bulkResult) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return errors.New(`CSV header must include a "title" column`)
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			res.add(rowError(row, errorResponse{
+				Error:   "INVALID_INPUT",
+				Message: "Invalid CSV row",
+				Details: parseErr.Error(),
+			}))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		a, err := albumFromRecord(record, columns)
+		if err != nil {
+			res.add(rowError(row, errorResponse{
+				Error:   "INVALID_INPUT",
+				Message: "Invalid album data",
+				Details: err.Error(),
+			}))
+			continue
+		}
+		res.add(svc.importAlbum(row, a, by))
+	}
+}
+
+// albumFromRecord builds an album from a CSV record using the column
+// positions found in the header. Missing columns are left empty.
+func albumFromRecord(record []string, columns map[string]int) (Album, error) {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	a := Album{ID: get("id"), Title: get("title"), Artist: get("artist"), Currency: strings.ToUpper(get("currency"))}
+	if a.Currency == "" {
+		a.Currency = defaultCurrency
+	}
+	if p := get("price"); p != "" {
+		price, err := parseMoney(p, a.Currency)
+		if err != nil {
+			return Album{}, fmt.Errorf("price: %w", err)
+		}
+		a.Price = price
+	} else if _, err := currencyExponent(a.Currency); err != nil {
+		return Album{}, fmt.Errorf("currency: %w", err)
+	}
+	return a, nil
+}
+
+// importAlbum validates and stores a single imported album. Unlike
+// POST /albums an import keeps the ID it was given, so a catalog can be
+// restored from an export; rows without one get a server-assigned ID.
+func (svc *Service) importAlbum(row int, a Album, by string) bulkRowResult {
+	if err := binding.Validator.ValidateStruct(a); err != nil {
+		return rowError(row, validationResponse(err, "Invalid album data", "Invalid album data"))
+	}
+	if err := checkImportedID(a.ID); err != nil {
+		return rowError(row, errorResponse{
+			Error:   "INVALID_INPUT",
+			Message: "Invalid album data",
+			Fields:  []fieldError{{Field: "id", Message: err.Error()}},
+		})
+	}
+
+	created, err := svc.store.Add(a, by)
+	if errors.Is(err, errAlbumExists) {
+		return bulkRowResult{Row: row, Status: http.StatusConflict, ID: a.ID, Error: &errorResponse{
+			Error:   "CONFLICT",
+			Message: "Album with this ID already exists",
+		}}
+	}
+	if err != nil {
+		return rowError(row, errorResponse{Error: "INTERNAL_ERROR", Message: "Failed to save album"})
+	}
+	svc.events.publish(eventCreated, created)
+	return bulkRowResult{Row: row, Status: http.StatusCreated, ID: created.ID}
+}
+
+// checkImportedID rejects a numeric ID too large for the store's ID
+// generator to move past, which would otherwise hand out negative IDs
+// or fail every later POST. Other IDs are opaque and always allowed.
+func checkImportedID(id string) error {
+	if id == "" || strings.TrimLeft(id, "0123456789") != "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(id); err != nil || n >= maxImportedID {
+		return fmt.Errorf("numeric IDs must be below %d", maxImportedID)
+	}
+	return nil
+}
+
+// rowError wraps an errorResponse as a failed row, picking the status
+// code from its error code.
+func rowError(row int, e errorResponse) bulkRowResult {
+	status := http.StatusBadRequest
+	if e.Error == "INTERNAL_ERROR" {
+		status = http.StatusInternalServerError
+	}
+	return bulkRowResult{Row: row, Status: status, Error: &e}
+}
+
+// add records a row result and updates the totals.
+func (r *bulkResult) add(row bulkRowResult) {
+	if row.Status == http.StatusCreated {
+		r.Created++
+	} else {
+		r.Failed++
+	}
+	r.Results = append(r.Results, row)
+}
+
+// exportAlbums streams the whole catalog as JSON Lines (the default) or
+// CSV, chosen by ?format=jsonl|csv.
+func (svc *Service) exportAlbums(c *gin.Context) {
+	format := c.DefaultQuery("format", "jsonl")
+	if format != "jsonl" && format != "csv" {
+		sendError(c, http.StatusBadRequest, "INVALID_INPUT", `format must be "jsonl" or "csv"`)
+		return
+	}
+
+	list, err := svc.store.List()
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list albums")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="albums.`+format+`"`)
+	if format == "csv" {
+		c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		writeCSV(c.Writer, list)
+		return
+	}
+	c.Header("Content-Type", mimeJSONL)
+	c.Status(http.StatusOK)
+	writeJSONL(c.Writer, list)
+}
+
+// writeJSONL writes one album per line, flushing as it goes so large
+// catalogs stream rather than buffer.
+func writeJSONL(w gin.ResponseWriter, list []Album) {
+	enc := json.NewEncoder(w)
+	for i, a := range list {
+		if err := enc.Encode(a); err != nil {
+			return
+		}
+		if i%100 == 99 {
+			w.Flush()
+		}
+	}
+	w.Flush()
+}
+
+// writeCSV writes a header row followed by one row per album.
+func writeCSV(w gin.ResponseWriter, list []Album) {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for i, a := range list {
+		cw.Write([]string{a.ID, a.Title, a.Artist, a.Price.format(a.currency()), a.currency()})
+		if i%100 == 99 {
+			cw.Flush()
+			w.Flush()
+		}
+	}
+	cw.Flush()
+	w.Flush()
+}
@@ -0,0 +1,88 @@
+package album
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// Config holds the album service settings. Each one can be set with a
+// command-line flag, whose default comes from an environment variable.
+type Config struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	ShutdownTimeout time.Duration
+	Store           string
+	StorePath       string
+	AuthConfig      string
+	RateLimitConfig string
+	TrustedProxies  string
+}
+
+// DefaultConfig returns the settings used when no flag or environment
+// variable overrides them.
+func DefaultConfig() Config {
+	return Config{
+		Addr:            "0.0.0.0:8080",
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		ShutdownTimeout: 15 * time.Second,
+		Store:           "memory",
+		StorePath:       "albums.jsonl",
+	}
+}
+
+// RegisterFlags defines a flag on fs for each setting. The current
+// values of cfg are the defaults, unless an environment variable
+// overrides them.
+func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Addr, "addr", envString("ALBUM_ADDR", portAddr(cfg.Addr)), "listen address (env ALBUM_ADDR, or PORT)")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", envDuration("ALBUM_READ_TIMEOUT", cfg.ReadTimeout), "max time to read a request (env ALBUM_READ_TIMEOUT)")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", envDuration("ALBUM_WRITE_TIMEOUT", cfg.WriteTimeout), "max time to write a response (env ALBUM_WRITE_TIMEOUT)")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", envDuration("ALBUM_SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout), "how long to drain in-flight requests on SIGTERM (env ALBUM_SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&cfg.Store, "store", envString("ALBUM_STORE", cfg.Store), `album store: "memory" or "file" (env ALBUM_STORE)`)
+	fs.StringVar(&cfg.StorePath, "store-path", envString("ALBUM_STORE_PATH", cfg.StorePath), "log file for the file store (env ALBUM_STORE_PATH)")
+	fs.StringVar(&cfg.AuthConfig, "auth-config", envString("ALBUM_AUTH_CONFIG", cfg.AuthConfig), "JSON file of API keys and JWT settings guarding write routes (env ALBUM_AUTH_CONFIG)")
+	fs.StringVar(&cfg.RateLimitConfig, "rate-limit-config", envString("ALBUM_RATE_LIMIT_CONFIG", cfg.RateLimitConfig), "JSON file of per-route token bucket limits (env ALBUM_RATE_LIMIT_CONFIG)")
+	fs.StringVar(&cfg.TrustedProxies, "trusted-proxies", envString("ALBUM_TRUSTED_PROXIES", cfg.TrustedProxies), "comma-separated IPs or CIDRs of proxies whose X-Forwarded-For is believed (env ALBUM_TRUSTED_PROXIES)")
+}
+
+// portAddr swaps the port of addr for PORT if it is set, keeping the
+// host addr listens on.
+func portAddr(addr string) string {
+	port := os.Getenv("PORT")
+	if port == "" {
+		return addr
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = ""
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// envString returns the environment variable key, or def if it's unset.
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envDuration parses the environment variable key as a time.Duration,
+// falling back to def if it's unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return d
+}
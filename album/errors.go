@@ -0,0 +1,122 @@
+package album
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// errorResponse is the envelope every album error is sent in,
+// matching the ErrorResponse shape of the hw5 product API. RequestID
+// repeats the X-Request-ID header so a pasted error body can be traced
+// back to its log line.
+type errorResponse struct {
+	Error     string       `json:"error" xml:"error"`
+	Message   string       `json:"message" xml:"message"`
+	Details   string       `json:"details,omitempty" xml:"details,omitempty"`
+	Fields    []fieldError `json:"fields,omitempty" xml:"fields>field,omitempty"`
+	RequestID string       `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// fieldError describes why a single request field was rejected.
+type fieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Message string `json:"message" xml:"message"`
+}
+
+func init() {
+	// Report field errors under their JSON (or query string) names
+	// rather than the Go ones.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, key := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// sendError aborts the request with an errorResponse.
+func sendError(c *gin.Context, statusCode int, errorCode string, message string) {
+	abortWithError(c, statusCode, errorResponse{
+		Error:     errorCode,
+		Message:   message,
+		RequestID: requestID(c),
+	})
+}
+
+// sendBindError reports a failed ShouldBindJSON or validation call,
+// listing each invalid field when the validator produced them.
+func sendBindError(c *gin.Context, err error) {
+	sendValidationError(c, err, "Invalid JSON format", "Invalid album data")
+}
+
+// sendQueryError reports a failed ShouldBindQuery call.
+func sendQueryError(c *gin.Context, err error) {
+	sendValidationError(c, err, "Invalid query parameters", "Invalid query parameters")
+}
+
+// sendValidationError sends a 400 for err, using decodeMessage when the
+// input couldn't be parsed at all and invalidMessage when it parsed but
+// failed validation.
+func sendValidationError(c *gin.Context, err error, decodeMessage, invalidMessage string) {
+	resp := validationResponse(err, decodeMessage, invalidMessage)
+	resp.RequestID = requestID(c)
+	abortWithError(c, http.StatusBadRequest, resp)
+}
+
+// validationResponse builds the errorResponse for a decode or
+// validation failure, listing each invalid field when the validator
+// produced them.
+func validationResponse(err error, decodeMessage, invalidMessage string) errorResponse {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return errorResponse{
+			Error:   "INVALID_INPUT",
+			Message: decodeMessage,
+			Details: err.Error(),
+		}
+	}
+
+	fields := make([]fieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, fieldError{
+			Field:   fe.Field(),
+			Message: validationMessage(fe),
+		})
+	}
+	return errorResponse{
+		Error:   "INVALID_INPUT",
+		Message: invalidMessage,
+		Fields:  fields,
+	}
+}
+
+// validationMessage turns a validator failure into a readable sentence.
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gte", "min":
+		return fmt.Sprintf("must be %s or greater", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be %s or less", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed %q validation", fe.Tag())
+	}
+}
@@ -0,0 +1,76 @@
+package album
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag returns the entity tag for an album, which is simply its version.
+func etag(a Album) string {
+	return `"` + strconv.Itoa(a.Version) + `"`
+}
+
+// gzipETag returns the entity tag of the gzipped representation tagged
+// tag, e.g. "3-gzip" for "3".
+func gzipETag(tag string) string {
+	return strings.TrimSuffix(tag, `"`) + `-gzip"`
+}
+
+// etagVersion returns the album version a strong entity tag from etag
+// or gzipETag names.
+func etagVersion(tag string) (int, bool) {
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(tag[1:len(tag)-1], "-gzip"))
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// setETag sets the ETag response header for a.
+func setETag(c *gin.Context, a Album) {
+	c.Header("ETag", etag(a))
+}
+
+// notModified reports whether the request's If-None-Match header
+// already names a's current version, using the weak comparison RFC 9110
+// prescribes for conditional GETs.
+func notModified(c *gin.Context, a Album) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(a) || tag == gzipETag(etag(a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireIfMatch returns the album version named by the If-Match
+// header. "*" matches any version. It aborts with 428 when the header
+// is missing and 412 when it can't name a version, reporting ok=false.
+func requireIfMatch(c *gin.Context) (version int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		sendError(c, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "If-Match header is required")
+		return 0, false
+	}
+	if header == "*" {
+		return anyVersion, true
+	}
+
+	n, ok := etagVersion(header)
+	if !ok {
+		sendError(c, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "If-Match does not match the current album version")
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,206 @@
+package album
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Album event types sent on GET /albums/events.
+const (
+	eventCreated  = "created"
+	eventUpdated  = "updated"
+	eventDeleted  = "deleted"
+	eventRestored = "restored"
+
+	// eventReset tells a resuming client that events it missed are no
+	// longer buffered, so it should re-fetch GET /albums.
+	eventReset = "reset"
+)
+
+// eventBufferSize is how many recent events are kept for clients
+// resuming with Last-Event-ID.
+const eventBufferSize = 1024
+
+// heartbeatInterval is how often an idle event stream gets a comment
+// line, so proxies don't time the connection out.
+const heartbeatInterval = 15 * time.Second
+
+// albumEvent is one change to the catalog, or a reset, which has no
+// album.
+type albumEvent struct {
+	ID    uint64    `json:"-"`
+	Type  string    `json:"type"`
+	Album *Album    `json:"album,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// broadcaster fans album events out to every subscribed stream and
+// keeps the most recent ones in a ring buffer for resuming clients.
+type broadcaster struct {
+	mu     sync.Mutex
+	nextID uint64
+	buffer []albumEvent // ring of the last len(buffer) events
+	size   int          // how many slots of buffer are filled
+	subs   map[chan albumEvent]struct{}
+	closed bool
+}
+
+func newBroadcaster(bufferSize int) *broadcaster {
+	return &broadcaster{
+		nextID: 1,
+		buffer: make([]albumEvent, bufferSize),
+		subs:   make(map[chan albumEvent]struct{}),
+	}
+}
+
+// publish records an event and sends it to every subscriber. A
+// subscriber too slow to keep up is dropped rather than allowed to block
+// the writer; its client can reconnect with Last-Event-ID.
+func (b *broadcaster) publish(typ string, a Album) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	e := albumEvent{ID: b.nextID, Type: typ, Album: &a, Time: time.Now().UTC()}
+	b.nextID++
+	b.buffer[e.ID%uint64(len(b.buffer))] = e
+	b.size = min(b.size+1, len(b.buffer))
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the buffered events after lastID, a channel of the
+// events that follow them, and a func to unsubscribe. The channel is
+// closed when the subscriber is dropped or the broadcaster is closed.
+//
+// When events after lastID have already left the buffer, or lastID was
+// never issued, as happens after a restart, the backlog starts with a
+// reset event followed by everything still buffered.
+func (b *broadcaster) subscribe(lastID uint64) ([]albumEvent, <-chan albumEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []albumEvent
+	oldest := b.nextID - uint64(b.size)
+	if lastID != 0 && (lastID+1 < oldest || lastID >= b.nextID) {
+		// The reset takes the ID just before the buffer, so a client
+		// that reconnects after it resumes from the buffer.
+		lastID = oldest - 1
+		backlog = append(backlog, albumEvent{ID: lastID, Type: eventReset, Time: time.Now().UTC()})
+	}
+	for id := oldest; id < b.nextID; id++ {
+		if id > lastID {
+			backlog = append(backlog, b.buffer[id%uint64(len(b.buffer))])
+		}
+	}
+
+	ch := make(chan albumEvent, 64)
+	if b.closed {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, cancel
+}
+
+// close ends every subscription, letting open streams finish so the
+// server can shut down.
+func (b *broadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// streamAlbumEvents sends album changes as Server-Sent Events. A client
+// reconnecting with Last-Event-ID first receives the buffered events it
+// missed, or a reset event if some of them are no longer buffered.
+func (svc *Service) streamAlbumEvents(c *gin.Context) {
+	var lastID uint64
+	if v := c.GetHeader("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			sendError(c, http.StatusBadRequest, "INVALID_INPUT", "Last-Event-ID must be an event id")
+			return
+		}
+		lastID = id
+	}
+
+	// The server's write timeout is meant for ordinary requests; a stream
+	// stays open for as long as the client wants it.
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	backlog, ch, cancel := svc.events.subscribe(lastID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, e := range backlog {
+		if writeEvent(c.Writer, e) != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if writeEvent(c.Writer, e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeEvent writes e in the text/event-stream format.
+func writeEvent(w gin.ResponseWriter, e albumEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
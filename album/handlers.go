@@ -0,0 +1,296 @@
+package album
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Album represents data about a record album. Price is held in the
+// minor unit of Currency; see money.go for its JSON form.
+type Album struct {
+	ID       string `json:"id"`
+	Title    string `json:"title" binding:"required"`
+	Artist   string `json:"artist"`
+	Price    money  `json:"price" binding:"gte=0"`
+	Currency string `json:"currency"`
+	Version  int    `json:"version"`
+}
+
+// SeedAlbums is the catalog a new store starts out with.
+var SeedAlbums = []Album{
+	{ID: "1", Title: "Blue Train", Artist: "John Coltrane", Price: 5699, Currency: "USD"},
+	{ID: "2", Title: "Jeru", Artist: "Gerry Mulligan", Price: 1799, Currency: "USD"},
+	{ID: "3", Title: "Sarah Vaughan and Clifford Brown", Artist: "Sarah Vaughan", Price: 3999, Currency: "USD"},
+}
+
+// getAlbums responds with one page of albums as JSON, filtered and
+// sorted according to the query string.
+func (svc *Service) getAlbums(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	q, err := bindAlbumQuery(c)
+	if err != nil {
+		sendQueryError(c, err)
+		return
+	}
+
+	list, err := svc.store.List()
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list albums")
+		return
+	}
+	render(c, format, http.StatusOK, q.page(list, c.Request.URL))
+}
+
+// postAlbums adds an album from JSON received in the request body and
+// points the Location header at its server-assigned ID.
+func (svc *Service) postAlbums(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	var newAlbum Album
+
+	// Call ShouldBindJSON to bind and validate the received
+	// JSON against newAlbum's binding tags.
+	if err := c.ShouldBindJSON(&newAlbum); err != nil {
+		sendBindError(c, err)
+		return
+	}
+
+	// IDs are assigned by the store, so ignore any the client sent.
+	newAlbum.ID = ""
+	created, err := svc.store.Add(newAlbum, actor(c))
+	if errors.Is(err, errAlbumExists) {
+		sendError(c, http.StatusConflict, "CONFLICT", "Album with this ID already exists")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save album")
+		return
+	}
+	svc.events.publish(eventCreated, created)
+	c.Header("Location", "/albums/"+created.ID)
+	setETag(c, created)
+	render(c, format, http.StatusCreated, created)
+}
+
+// getAlbumByID locates the album whose ID value matches the id
+// parameter sent by the client, then returns that album as a response.
+func (svc *Service) getAlbumByID(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+
+	a, err := svc.store.Get(id)
+	if errors.Is(err, errAlbumNotFound) {
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Album not found")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to load album")
+		return
+	}
+
+	setETag(c, a)
+	if notModified(c, a) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	render(c, format, http.StatusOK, a)
+}
+
+// albumPatch holds the fields a PATCH request may change. A nil field
+// is left as it is on the stored album.
+type albumPatch struct {
+	ID       *string      `json:"id"`
+	Title    *string      `json:"title"`
+	Artist   *string      `json:"artist"`
+	Price    *json.Number `json:"price"`
+	Currency *string      `json:"currency"`
+}
+
+// putAlbum replaces the album named by the id parameter with the JSON
+// received in the request body, provided If-Match names its current
+// version.
+func (svc *Service) putAlbum(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	// The body may omit the ID, so start from the one in the path; it
+	// can't move the album to another one though.
+	updated := Album{ID: id}
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		sendBindError(c, err)
+		return
+	}
+	if updated.ID != id {
+		sendError(c, http.StatusBadRequest, "INVALID_INPUT", "Album ID in body must match path parameter")
+		return
+	}
+
+	svc.saveUpdate(c, format, updated, version)
+}
+
+// patchAlbum applies the fields present in the request body to the
+// album named by the id parameter, provided If-Match names its current
+// version.
+func (svc *Service) patchAlbum(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var patch albumPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		sendBindError(c, err)
+		return
+	}
+	if patch.ID != nil && *patch.ID != id {
+		sendError(c, http.StatusBadRequest, "INVALID_INPUT", "Album ID in body must match path parameter")
+		return
+	}
+
+	current, err := svc.store.Get(id)
+	if errors.Is(err, errAlbumNotFound) {
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Album not found")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to load album")
+		return
+	}
+	if version == anyVersion {
+		// Pin the merge to the version we read so a concurrent write
+		// between Get and Update can't be silently overwritten.
+		version = current.Version
+	}
+
+	if patch.Title != nil {
+		current.Title = *patch.Title
+	}
+	if patch.Artist != nil {
+		current.Artist = *patch.Artist
+	}
+	if err := applyPricePatch(&current, patch); err != nil {
+		sendError(c, http.StatusBadRequest, "INVALID_INPUT", "Invalid album data: "+err.Error())
+		return
+	}
+
+	// Validate the merged album so a patch can't blank out a required field.
+	if err := binding.Validator.ValidateStruct(current); err != nil {
+		sendBindError(c, err)
+		return
+	}
+
+	svc.saveUpdate(c, format, current, version)
+}
+
+// applyPricePatch sets current's price and currency from a patch. A
+// new currency without a new price keeps the same minor-unit amount
+// only when both currencies share an exponent.
+func applyPricePatch(current *Album, patch albumPatch) error {
+	currency := current.currency()
+	if patch.Currency != nil {
+		currency = strings.ToUpper(*patch.Currency)
+		if _, err := currencyExponent(currency); err != nil {
+			return err
+		}
+	}
+
+	price := current.Price
+	switch {
+	case patch.Price != nil:
+		p, err := parseMoney(patch.Price.String(), currency)
+		if err != nil {
+			return err
+		}
+		price = p
+	case currency != current.currency():
+		// Re-read the current amount in the new currency so 17.99 USD
+		// becomes 17.99 EUR, not 1799 JPY.
+		p, err := parseMoney(current.Price.format(current.currency()), currency)
+		if err != nil {
+			return err
+		}
+		price = p
+	}
+
+	current.Price = price
+	current.Currency = currency
+	return nil
+}
+
+// saveUpdate writes an updated album back to the store if it is still
+// at the given version and responds with the stored result in format.
+func (svc *Service) saveUpdate(c *gin.Context, format string, a Album, version int) {
+	saved, err := svc.store.Update(a, version, actor(c))
+	if errors.Is(err, errAlbumNotFound) {
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Album not found")
+		return
+	}
+	if errors.Is(err, errVersionMismatch) {
+		sendError(c, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "If-Match does not match the current album version")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save album")
+		return
+	}
+	svc.events.publish(eventUpdated, saved)
+	setETag(c, saved)
+	render(c, format, http.StatusOK, saved)
+}
+
+// deleteAlbum soft-deletes the album named by the id parameter,
+// provided If-Match names its current version. It stays restorable
+// through POST /albums/:id/restore.
+func (svc *Service) deleteAlbum(c *gin.Context) {
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	err := svc.store.Delete(id, version, actor(c))
+	if errors.Is(err, errAlbumNotFound) {
+		sendError(c, http.StatusNotFound, "NOT_FOUND", "Album not found")
+		return
+	}
+	if errors.Is(err, errVersionMismatch) {
+		sendError(c, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "If-Match does not match the current album version")
+		return
+	}
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete album")
+		return
+	}
+	svc.events.publish(eventDeleted, Album{ID: id})
+	c.Status(http.StatusNoContent)
+}
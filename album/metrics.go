@@ -0,0 +1,84 @@
+package album
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "album_http_requests_total",
+			Help: "Album API requests by method, route and status code.",
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "album_http_request_duration_seconds",
+			Help:    "Album API request latency by method, route and status code.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	httpRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "album_http_requests_in_flight",
+			Help: "Album API requests currently being served.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, httpRequestsInFlight)
+}
+
+// metricsMiddleware records a count, latency observation and in-flight
+// gauge for every request. Routes are labelled by their template (e.g.
+// /albums/:id) so label cardinality stays bounded.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		httpRequestsInFlight.Inc()
+		start := time.Now()
+
+		// A panicking handler unwinds through here on its way to
+		// gin.Recovery, which is registered further out and only then
+		// writes the 500, so count it as one ourselves.
+		finished := false
+		defer func() {
+			httpRequestsInFlight.Dec()
+			code := c.Writer.Status()
+			if !finished && !c.Writer.Written() {
+				code = http.StatusInternalServerError
+			}
+			route := routeLabel(c)
+			status := strconv.Itoa(code)
+			httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+			httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+		}()
+
+		c.Next()
+		finished = true
+	}
+}
+
+// routeLabel names the route template c matched, or "unmatched" when
+// it matched none.
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// metricsHandler serves the Prometheus text exposition format.
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,202 @@
+package album
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// defaultCurrency is assumed for albums that don't name one, which
+// covers every album stored before prices carried a currency.
+const defaultCurrency = "USD"
+
+// maxExponent is the largest number of minor-unit digits among the
+// supported currencies. Prices in different currencies are compared
+// after scaling to it.
+const maxExponent = 3
+
+// currencyExponents lists the supported ISO 4217 currencies and how many
+// digits their minor unit has (cents for USD, none for JPY).
+var currencyExponents = map[string]int{
+	"USD": 2, "EUR": 2, "GBP": 2, "CAD": 2, "AUD": 2, "CHF": 2, "CNY": 2,
+	"INR": 2, "MXN": 2, "BRL": 2, "SEK": 2, "NOK": 2, "DKK": 2, "NZD": 2,
+	"JPY": 0, "KRW": 0,
+	"BHD": 3, "KWD": 3,
+}
+
+// money is an amount in a currency's minor unit, e.g. cents. Keeping it
+// integral means summing a catalog never drifts the way float64 does.
+type money int64
+
+// errTooPrecise is returned for an amount with more decimal places than
+// its currency's minor unit.
+var errTooPrecise = errors.New("has more decimal places than the currency allows")
+
+// currencyExponent returns the minor-unit digits of an ISO 4217 code.
+func currencyExponent(currency string) (int, error) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", currency)
+	}
+	return exp, nil
+}
+
+// parseMoney converts a decimal string such as "17.99" or "1.799e1"
+// into minor units of currency, rejecting values that don't fit exactly.
+func parseMoney(s, currency string) (money, error) {
+	exp, err := currencyExponent(currency)
+	if err != nil {
+		return 0, err
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("amount %s %w", s, errTooPrecise)
+	}
+	// The amount has to fit in an int64 even after money.scaled, or
+	// filtering and sorting by price would overflow.
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s is out of range", s)
+	}
+	n, f := r.Num().Int64(), scaleFactor(exp)
+	if n > math.MaxInt64/f || n < math.MinInt64/f {
+		return 0, fmt.Errorf("amount %s is out of range", s)
+	}
+	return money(n), nil
+}
+
+// format renders m as a decimal string with currency's number of
+// minor-unit digits, e.g. "17.99" for 1799 USD or "1800" for 1800 JPY.
+func (m money) format(currency string) string {
+	exp, err := currencyExponent(currency)
+	if err != nil || exp == 0 {
+		return fmt.Sprint(int64(m))
+	}
+
+	sign, n := "", int64(m)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	scale := int64(math.Pow10(exp))
+	return fmt.Sprintf("%s%d.%0*d", sign, n/scale, exp, n%scale)
+}
+
+// scaled returns m in units of 10^-maxExponent, so amounts in
+// currencies with different minor units can be compared.
+func (m money) scaled(currency string) int64 {
+	exp, err := currencyExponent(currency)
+	if err != nil {
+		exp = maxExponent
+	}
+	return int64(m) * scaleFactor(exp)
+}
+
+// scaleFactor is what money.scaled multiplies amounts in a currency
+// with exp minor-unit digits by.
+func scaleFactor(exp int) int64 {
+	return int64(math.Pow10(maxExponent - exp))
+}
+
+// scaledAmount converts a plain decimal amount, such as a price filter,
+// to the same units as money.scaled, failing if it doesn't fit in an
+// int64.
+func scaledAmount(f float64) (int64, error) {
+	// float64(math.MaxInt64) rounds up to 2^63, which is already out of
+	// range, hence the strict upper bound.
+	x := math.Round(f * math.Pow10(maxExponent))
+	if !(x >= math.MinInt64 && x < math.MaxInt64) {
+		return 0, fmt.Errorf("amount %g is out of range", f)
+	}
+	return int64(x), nil
+}
+
+// albumJSON is the wire form of an album in JSON and XML. Price stays a
+// decimal number in major units, as it was when album.Price was a
+// float64.
+type albumJSON struct {
+	ID       string      `json:"id" xml:"id"`
+	Title    string      `json:"title" xml:"title"`
+	Artist   string      `json:"artist" xml:"artist"`
+	Price    json.Number `json:"price" xml:"price"`
+	Currency string      `json:"currency" xml:"currency"`
+	Version  int         `json:"version" xml:"version"`
+}
+
+// wire returns a's wire form.
+func (a Album) wire() albumJSON {
+	return albumJSON{
+		ID:       a.ID,
+		Title:    a.Title,
+		Artist:   a.Artist,
+		Price:    json.Number(a.Price.format(a.currency())),
+		Currency: a.currency(),
+		Version:  a.Version,
+	}
+}
+
+// MarshalJSON writes the price as a decimal number in major units.
+func (a Album) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.wire())
+}
+
+// MarshalXML writes the price as a decimal number in major units. An
+// album encoded on its own, rather than as a tagged field, is named
+// after its type; it's renamed to match the lowercase element names
+// used everywhere else.
+func (a Album) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if start.Name.Local == "Album" {
+		start.Name.Local = "album"
+	}
+	return e.EncodeElement(a.wire(), start)
+}
+
+// UnmarshalJSON reads a decimal price, in the album's currency or
+// defaultCurrency, into minor units.
+func (a *Album) UnmarshalJSON(data []byte) error {
+	// Start from a's current fields so that, like the default decoder,
+	// keys missing from data leave them alone.
+	w := albumJSON{ID: a.ID, Title: a.Title, Artist: a.Artist, Currency: a.Currency, Version: a.Version}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+
+	currency := strings.ToUpper(w.Currency)
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	price := a.Price
+	if w.Price != "" {
+		p, err := parseMoney(w.Price.String(), currency)
+		if err != nil {
+			return fmt.Errorf("price: %w", err)
+		}
+		price = p
+	} else if _, err := currencyExponent(currency); err != nil {
+		return fmt.Errorf("currency: %w", err)
+	}
+
+	*a = Album{
+		ID:       w.ID,
+		Title:    w.Title,
+		Artist:   w.Artist,
+		Price:    price,
+		Currency: currency,
+		Version:  w.Version,
+	}
+	return nil
+}
+
+// currency returns the album's currency, defaulting to defaultCurrency.
+func (a Album) currency() string {
+	if a.Currency == "" {
+		return defaultCurrency
+	}
+	return a.Currency
+}
@@ -0,0 +1,204 @@
+package album
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+// gzipMinSize is the smallest response body worth compressing. Single
+// albums and errors stay below it; catalog pages and searches don't.
+const gzipMinSize = 1024
+
+// responseFormats are the media types render can produce, in order of
+// preference when the client accepts several equally.
+var responseFormats = []string{
+	binding.MIMEJSON,
+	binding.MIMEXML,
+	binding.MIMEXML2,
+	binding.MIMEMSGPACK2,
+	binding.MIMEMSGPACK,
+}
+
+// gzipWriters recycles gzip writers, whose compression state is too
+// large to allocate per response.
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// msgpackHandle encodes with the current MessagePack spec, so strings
+// and binary data get their own types.
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// negotiate returns the response format the Accept header asks for. A
+// client accepting none of them gets 406 and ok=false. Handlers call it
+// before touching the store, so an unacceptable request changes nothing.
+func negotiate(c *gin.Context) (format string, ok bool) {
+	format = c.NegotiateFormat(responseFormats...)
+	if format == "" {
+		sendError(c, http.StatusNotAcceptable, "NOT_ACCEPTABLE",
+			"Accept must allow "+strings.Join(responseFormats, ", "))
+		return "", false
+	}
+	return format, true
+}
+
+// render writes obj with status in format, as chosen by negotiate:
+// compact JSON by default, indented JSON with ?pretty=1, XML or
+// MessagePack. Bodies of at least gzipMinSize are gzipped for clients
+// that accept it.
+func render(c *gin.Context, format string, status int, obj any) {
+	body, err := encodeResponse(format, obj, wantsPretty(c))
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to encode response")
+		return
+	}
+
+	c.Header("Vary", "Accept, Accept-Encoding")
+	contentType := contentTypeOf(format)
+	if len(body) < gzipMinSize || !acceptsGzip(c.Request) {
+		c.Data(status, contentType, body)
+		return
+	}
+
+	// The gzipped bytes are a different representation, so they need
+	// their own strong validator.
+	if tag := c.Writer.Header().Get("ETag"); tag != "" {
+		c.Header("ETag", gzipETag(tag))
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Encoding", "gzip")
+	c.Status(status)
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(c.Writer)
+	zw.Write(body)
+	zw.Close()
+}
+
+// abortWithError aborts the request with resp in the format the Accept
+// header asks for, falling back to JSON when it allows none of them so
+// the 406 itself can still be read.
+func abortWithError(c *gin.Context, status int, resp errorResponse) {
+	format := c.NegotiateFormat(responseFormats...)
+	if format == "" {
+		format = binding.MIMEJSON
+	}
+	body, err := encodeResponse(format, resp, wantsPretty(c))
+	if err != nil {
+		c.AbortWithStatusJSON(status, resp)
+		return
+	}
+	c.Header("Vary", "Accept")
+	c.Data(status, contentTypeOf(format), body)
+	c.Abort()
+}
+
+// contentTypeOf returns the Content-Type header for a response format.
+// MessagePack is binary, so it takes no charset.
+func contentTypeOf(format string) string {
+	if format == binding.MIMEMSGPACK || format == binding.MIMEMSGPACK2 {
+		return format
+	}
+	return format + "; charset=utf-8"
+}
+
+// wantsPretty reports whether ?pretty asks for indented output.
+func wantsPretty(c *gin.Context) bool {
+	pretty, _ := strconv.ParseBool(c.Query("pretty"))
+	return pretty
+}
+
+// encodeResponse encodes obj as format. Pretty only applies to JSON and
+// XML.
+func encodeResponse(format string, obj any, pretty bool) ([]byte, error) {
+	switch format {
+	case binding.MIMEXML, binding.MIMEXML2:
+		var body []byte
+		var err error
+		if pretty {
+			body, err = xml.MarshalIndent(obj, "", "    ")
+		} else {
+			body, err = xml.Marshal(obj)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), body...), nil
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		v, err := msgpackValue(obj)
+		if err != nil {
+			return nil, err
+		}
+		var body []byte
+		err = codec.NewEncoderBytes(&body, msgpackHandle).Encode(v)
+		return body, err
+	default:
+		if pretty {
+			return json.MarshalIndent(obj, "", "    ")
+		}
+		return json.Marshal(obj)
+	}
+}
+
+// msgpackValue converts obj to the generic maps, slices and scalars of
+// its JSON form, so MessagePack clients see the same field names and
+// decimal prices as JSON ones without every type having to describe
+// itself twice.
+func msgpackValue(obj any) (any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return numbersToScalars(v), nil
+}
+
+// numbersToScalars replaces every json.Number in v with an int64 when
+// it is whole and a float64 otherwise.
+func numbersToScalars(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbersToScalars(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbersToScalars(e)
+		}
+	}
+	return v
+}
+
+// acceptsGzip reports whether r's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		return !ok || (q != "0" && q != "0.0" && q != "0.00" && q != "0.000")
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package album
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIJSON is the OpenAPI 3 document for the album routes. Every
+// route registered in Router under /albums must have an operation in
+// it; TestOpenAPICoversRoutes keeps the two in step, and
+// TestOpenAPISchemasMatchTypes does the same for its schemas and the
+// types handlers send.
+//
+//go:embed openapi.json
+var openAPIJSON []byte
+
+// apiSpec is openAPIJSON parsed and checked.
+var apiSpec = mustLoadOpenAPI()
+
+// mustLoadOpenAPI parses and validates openAPIJSON. It's embedded, so
+// any error is a bug in the document.
+func mustLoadOpenAPI() *openapi3.T {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPIJSON)
+	if err != nil {
+		panic(fmt.Sprintf("parse openapi.json: %v", err))
+	}
+	if err := doc.Validate(openapi3.NewLoader().Context); err != nil {
+		panic(fmt.Sprintf("validate openapi.json: %v", err))
+	}
+	return doc
+}
+
+// openAPIHandler serves the OpenAPI document.
+func openAPIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPIJSON)
+}
+
+// openAPIMiddleware rejects requests whose parameters or JSON body
+// don't match their operation in doc, with the same INVALID_INPUT
+// envelope the handlers use. Routes the document doesn't describe pass
+// through untouched, and so do JSON Lines and CSV bodies, which the
+// import handler streams and checks row by row. Credentials are left
+// to requireScope, which Router runs first.
+func openAPIMiddleware(doc *openapi3.T) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, params, ok := specRoute(doc, c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				// Handlers apply their own defaults; the document's
+				// are for readers, so don't write them into the request.
+				SkipSettingDefaults: true,
+				ExcludeRequestBody:  mediaType != "application/json",
+				MultiError:          true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			sendSpecError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// specRoute finds the operation in doc for the gin route c matched.
+// Whole-segment parameters like :id become {id}; any other parameter,
+// like the action in /albums:action, is filled in with its value, so
+// POST /albums:bulk maps to the /albums:bulk path.
+func specRoute(doc *openapi3.T, c *gin.Context) (*routers.Route, map[string]string, bool) {
+	full := c.FullPath()
+	if full == "" {
+		return nil, nil, false
+	}
+
+	path := full
+	params := make(map[string]string)
+	for _, p := range c.Params {
+		if strings.Contains(full, "/:"+p.Key) {
+			path = strings.Replace(path, ":"+p.Key, "{"+p.Key+"}", 1)
+			params[p.Key] = p.Value
+		} else {
+			path = strings.Replace(path, ":"+p.Key, p.Value, 1)
+		}
+	}
+
+	item := doc.Paths.Value(path)
+	if item == nil {
+		return nil, nil, false
+	}
+	op := item.GetOperation(c.Request.Method)
+	if op == nil {
+		return nil, nil, false
+	}
+	return &routers.Route{Spec: doc, Path: path, PathItem: item, Method: c.Request.Method, Operation: op}, params, true
+}
+
+// sendSpecError reports a failed ValidateRequest, listing each invalid
+// parameter or body field.
+func sendSpecError(c *gin.Context, err error) {
+	resp := errorResponse{
+		Error:     "INVALID_INPUT",
+		Message:   "Request does not match the API spec",
+		RequestID: requestID(c),
+	}
+	for _, e := range flattenSpecErrors(err) {
+		var reqErr *openapi3filter.RequestError
+		if !errors.As(e, &reqErr) {
+			resp.Fields = append(resp.Fields, fieldError{Message: e.Error()})
+			continue
+		}
+		if reqErr.Parameter != nil {
+			resp.Fields = append(resp.Fields, specFieldErrors(reqErr.Err, reqErr.Parameter.Name)...)
+			continue
+		}
+
+		var parseErr *openapi3filter.ParseError
+		if errors.As(reqErr.Err, &parseErr) {
+			resp.Message = "Invalid JSON format"
+			resp.Details = parseErr.Error()
+			continue
+		}
+		resp.Fields = append(resp.Fields, specFieldErrors(reqErr.Err, "")...)
+	}
+	abortWithError(c, http.StatusBadRequest, resp)
+}
+
+// specFieldErrors turns the errors under a RequestError into field
+// errors. Schema errors inside a body are named by their JSON pointer,
+// and everything else by field.
+func specFieldErrors(err error, field string) []fieldError {
+	var out []fieldError
+	for _, e := range flattenSpecErrors(err) {
+		var schemaErr *openapi3.SchemaError
+		var parseErr *openapi3filter.ParseError
+		switch {
+		case errors.As(e, &schemaErr):
+			name := field
+			if pointer := schemaErr.JSONPointer(); name == "" && len(pointer) > 0 {
+				name = strings.Join(pointer, ".")
+			}
+			out = append(out, fieldError{Field: name, Message: schemaErr.Reason})
+		case errors.As(e, &parseErr):
+			out = append(out, fieldError{Field: field, Message: parseErr.Reason})
+		default:
+			out = append(out, fieldError{Field: field, Message: e.Error()})
+		}
+	}
+	return out
+}
+
+// flattenSpecErrors expands nested openapi3.MultiErrors. It doesn't
+// look through wrappers, so a RequestError stays in one piece.
+func flattenSpecErrors(err error) []error {
+	me, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	var out []error
+	for _, e := range me {
+		out = append(out, flattenSpecErrors(e)...)
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package album
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPageLimit is how many albums GET /albums returns when the
+// client doesn't ask for a specific limit.
+const defaultPageLimit = 100
+
+// albumQuery holds the filtering, sorting and paging parameters
+// accepted by GET /albums.
+type albumQuery struct {
+	Artist   string   `form:"artist"`
+	MinPrice *float64 `form:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice *float64 `form:"max_price" binding:"omitempty,gte=0"`
+	Sort     string   `form:"sort" binding:"omitempty,oneof=price -price title -title"`
+	Offset   int      `form:"offset" binding:"gte=0"`
+	Limit    int      `form:"limit" binding:"omitempty,min=1,max=1000"`
+
+	// minScaled and maxScaled are MinPrice and MaxPrice in the units
+	// of money.scaled, filled in by bindAlbumQuery.
+	minScaled, maxScaled int64
+}
+
+// albumPage is one page of GET /albums results.
+type albumPage struct {
+	Albums []Album `json:"albums" xml:"albums>album"`
+	Total  int     `json:"total" xml:"total"`
+	Offset int     `json:"offset" xml:"offset"`
+	Limit  int     `json:"limit" xml:"limit"`
+	Next   string  `json:"next,omitempty" xml:"next,omitempty"`
+}
+
+// match reports whether a passes the query's filters.
+func (q albumQuery) match(a Album) bool {
+	if q.Artist != "" && !strings.EqualFold(a.Artist, q.Artist) {
+		return false
+	}
+	price := a.Price.scaled(a.currency())
+	if q.MinPrice != nil && price < q.minScaled {
+		return false
+	}
+	if q.MaxPrice != nil && price > q.maxScaled {
+		return false
+	}
+	return true
+}
+
+// sortAlbums orders list in place by the query's sort key. A leading
+// "-" sorts descending; ties keep their insertion order.
+func (q albumQuery) sortAlbums(list []Album) {
+	key, desc := strings.CutPrefix(q.Sort, "-")
+
+	var less func(a, b Album) bool
+	switch key {
+	case "price":
+		less = func(a, b Album) bool { return a.Price.scaled(a.currency()) < b.Price.scaled(b.currency()) }
+	case "title":
+		less = func(a, b Album) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	default:
+		return
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if desc {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+}
+
+// page filters, sorts and slices list according to the query. next is
+// the URL of the following page, built from reqURL.
+func (q albumQuery) page(list []Album, reqURL *url.URL) albumPage {
+	filtered := make([]Album, 0, len(list))
+	for _, a := range list {
+		if q.match(a) {
+			filtered = append(filtered, a)
+		}
+	}
+	q.sortAlbums(filtered)
+
+	p := albumPage{
+		Albums: []Album{},
+		Total:  len(filtered),
+		Offset: q.Offset,
+		Limit:  q.Limit,
+	}
+	if q.Offset < len(filtered) {
+		end := min(q.Offset+q.Limit, len(filtered))
+		p.Albums = filtered[q.Offset:end]
+		if end < len(filtered) {
+			p.Next = nextPageURL(reqURL, end, q.Limit)
+		}
+	}
+	return p
+}
+
+// bindAlbumQuery parses the GET /albums query string, applying the
+// default page size. Price bounds too large to compare with any price
+// are rejected.
+func bindAlbumQuery(c *gin.Context) (albumQuery, error) {
+	var q albumQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		return q, err
+	}
+	var err error
+	if q.MinPrice != nil {
+		if q.minScaled, err = scaledAmount(*q.MinPrice); err != nil {
+			return q, fmt.Errorf("min_price: %w", err)
+		}
+	}
+	if q.MaxPrice != nil {
+		if q.maxScaled, err = scaledAmount(*q.MaxPrice); err != nil {
+			return q, fmt.Errorf("max_price: %w", err)
+		}
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultPageLimit
+	}
+	return q, nil
+}
+
+// nextPageURL copies reqURL's path and query, replacing the paging
+// parameters so the link keeps the same filters and sort order.
+func nextPageURL(reqURL *url.URL, offset, limit int) string {
+	values := reqURL.Query()
+	values.Set("offset", strconv.Itoa(offset))
+	values.Set("limit", strconv.Itoa(limit))
+	return reqURL.Path + "?" + values.Encode()
+}
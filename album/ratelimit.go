@@ -0,0 +1,205 @@
+package album
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimit is a token bucket size: Burst requests at once, refilled at
+// Rate requests per second.
+type rateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// rateLimitConfig is the JSON rate limit config file. Routes are keyed
+// by method and route template, e.g. "POST /albums" or
+// "GET /albums/:id"; routes not listed use Default.
+type rateLimitConfig struct {
+	Default rateLimit            `json:"default"`
+	Routes  map[string]rateLimit `json:"routes"`
+}
+
+// rateLimits holds one limiter per route, each with a bucket per client.
+type rateLimits struct {
+	cfg rateLimitConfig
+
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+// loadRateLimits reads the rate limit config file at path.
+func loadRateLimits(path string) (*rateLimits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rate limit config: %w", err)
+	}
+	var cfg rateLimitConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse rate limit config: %w", err)
+	}
+	if err := cfg.Default.validate(); err != nil {
+		return nil, fmt.Errorf("rate limit config default: %w", err)
+	}
+	for route, l := range cfg.Routes {
+		if err := l.validate(); err != nil {
+			return nil, fmt.Errorf("rate limit config %q: %w", route, err)
+		}
+	}
+	return newRateLimits(cfg), nil
+}
+
+func newRateLimits(cfg rateLimitConfig) *rateLimits {
+	return &rateLimits{cfg: cfg, limiters: make(map[string]*rateLimiter)}
+}
+
+func (l rateLimit) validate() error {
+	if l.Rate <= 0 || l.Burst < 1 {
+		return fmt.Errorf("rate must be positive and burst at least 1, got rate %v burst %d", l.Rate, l.Burst)
+	}
+	return nil
+}
+
+// forRoute returns the limiter for a route, creating it on first use.
+func (r *rateLimits) forRoute(route string) *rateLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.limiters[route]
+	if !ok {
+		limit, ok := r.cfg.Routes[route]
+		if !ok {
+			limit = r.cfg.Default
+		}
+		l = newRateLimiter(limit)
+		r.limiters[route] = l
+	}
+	return l
+}
+
+// rateLimiter keeps a token bucket per client for a single route.
+type rateLimiter struct {
+	limit rateLimit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit rateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// rateDecision is the outcome of taking a token from a bucket.
+type rateDecision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration // until the bucket is full again
+	retryAfter time.Duration // until the next token, when not allowed
+}
+
+// take refills client's bucket for the time since it was last used and
+// tries to take a token from it.
+func (l *rateLimiter) take(client string, now time.Time) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+
+	d := rateDecision{allowed: b.tokens >= 1}
+	if d.allowed {
+		b.tokens--
+	} else {
+		d.retryAfter = l.secondsFor(1 - b.tokens)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = l.secondsFor(float64(l.limit.Burst) - b.tokens)
+	return d
+}
+
+// secondsFor returns how long the bucket takes to refill n tokens.
+func (l *rateLimiter) secondsFor(n float64) time.Duration {
+	return time.Duration(n / l.limit.Rate * float64(time.Second))
+}
+
+// sweep drops buckets that have been idle long enough to refill
+// completely, since they'd be recreated in the same state. It runs at
+// most once a minute. The caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := l.secondsFor(float64(l.limit.Burst))
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// rateLimitMiddleware throttles each client per route, answering 429
+// with Retry-After once its bucket is empty. Every response carries
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (in
+// seconds).
+func (svc *Service) rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if svc.limits == nil || c.FullPath() == "" {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		l := svc.limits.forRoute(route)
+		d := l.take(svc.rateLimitClient(c), time.Now())
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(l.limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+		if !d.allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(d.retryAfter)))
+			sendError(c, http.StatusTooManyRequests, "RATE_LIMITED", "Too many requests, retry later")
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitClient identifies who a request is throttled as: the name of
+// a valid API key or JWT subject when it carries one, and its client IP
+// otherwise. Invalid credentials fall back to the IP so they can't be
+// rotated to dodge the limit.
+func (svc *Service) rateLimitClient(c *gin.Context) string {
+	if svc.auth != nil && (c.GetHeader("X-API-Key") != "" || c.GetHeader("Authorization") != "") {
+		if name, _, err := svc.auth.authenticate(c.Request); err == nil {
+			return "principal:" + name
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,166 @@
+package album
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Weights given to a query token matching each field. A title match
+// counts for more than an artist match.
+const (
+	titleWeight  = 2
+	artistWeight = 1
+)
+
+// defaultSearchLimit is how many results GET /albums/search returns
+// when the client doesn't ask for a specific limit.
+const defaultSearchLimit = 20
+
+// searchHit is one ranked result of a search.
+type searchHit struct {
+	Album Album `json:"album" xml:"album"`
+	Score int   `json:"score" xml:"score"`
+}
+
+// searchIndex is an inverted index from lower-cased tokens in album
+// titles and artists to the IDs of the albums containing them, with
+// the weight each album earns for that token.
+type searchIndex struct {
+	postings map[string]map[string]int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{postings: make(map[string]map[string]int)}
+}
+
+// tokenize splits s into lower-cased runs of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes a's title and artist.
+func (ix *searchIndex) add(a Album) {
+	for token, weight := range albumTokens(a) {
+		ids := ix.postings[token]
+		if ids == nil {
+			ids = make(map[string]int)
+			ix.postings[token] = ids
+		}
+		ids[a.ID] = weight
+	}
+}
+
+// remove drops a from the index. a must be the album as it was when it
+// was added, so the same tokens are found.
+func (ix *searchIndex) remove(a Album) {
+	for token := range albumTokens(a) {
+		delete(ix.postings[token], a.ID)
+		if len(ix.postings[token]) == 0 {
+			delete(ix.postings, token)
+		}
+	}
+}
+
+// search scores every album matching at least one query token by the
+// summed weight of its matches and returns the IDs with their scores,
+// best first. order breaks ties so results are stable.
+func (ix *searchIndex) search(query string, order func(id string) int) []scoredID {
+	scores := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		for id, weight := range ix.postings[token] {
+			scores[id] += weight
+		}
+	}
+
+	hits := make([]scoredID, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, scoredID{id: id, score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return order(hits[i].id) < order(hits[j].id)
+	})
+	return hits
+}
+
+// scoredID is an album ID and its search score.
+type scoredID struct {
+	id    string
+	score int
+}
+
+// albumTokens returns each distinct token in a's title and artist with
+// the combined weight of the fields it appears in.
+func albumTokens(a Album) map[string]int {
+	tokens := make(map[string]int)
+	for _, t := range dedupe(tokenize(a.Title)) {
+		tokens[t] += titleWeight
+	}
+	for _, t := range dedupe(tokenize(a.Artist)) {
+		tokens[t] += artistWeight
+	}
+	return tokens
+}
+
+// dedupe returns tokens with repeats removed, keeping the first of each.
+func dedupe(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	out := tokens[:0]
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// searchQuery holds the parameters accepted by GET /albums/search.
+type searchQuery struct {
+	Q     string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// searchResults is the response body of GET /albums/search.
+type searchResults struct {
+	Query   string      `json:"query" xml:"query"`
+	Results []searchHit `json:"results" xml:"results>result"`
+}
+
+// searchAlbums responds with the albums best matching ?q=, ranked by
+// how many of its tokens appear in each title and artist.
+func (svc *Service) searchAlbums(c *gin.Context) {
+	format, ok := negotiate(c)
+	if !ok {
+		return
+	}
+
+	var q searchQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		sendQueryError(c, err)
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultSearchLimit
+	}
+
+	hits, err := svc.store.Search(q.Q, q.Limit)
+	if err != nil {
+		sendError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to search albums")
+		return
+	}
+	render(c, format, http.StatusOK, searchResults{Query: q.Q, Results: hits})
+}
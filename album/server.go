@@ -0,0 +1,55 @@
+package album
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+)
+
+// runServer serves handler on cfg.Addr until the process receives
+// SIGINT or SIGTERM, then stops accepting connections and waits up to
+// cfg.ShutdownTimeout for in-flight requests to finish. onShutdown is
+// called as soon as shutdown starts.
+func runServer(cfg Config, handler http.Handler, onShutdown func()) error {
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+	// Event streams never finish on their own, so end them when shutdown
+	// starts instead of waiting out the drain deadline.
+	srv.RegisterOnShutdown(onShutdown)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("Album service listening on %s", cfg.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		// The listener failed before we were asked to stop.
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, draining requests for up to %v", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("Album service stopped")
+	return nil
+}
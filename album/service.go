@@ -0,0 +1,166 @@
+package album
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Service is the album API: a store plus the optional auth and rate
+// limiting guarding it, and the feed its changes are published on.
+type Service struct {
+	store Store
+
+	// auth guards the mutating routes. When it is nil, which is the case
+	// unless an auth config is given, every request is allowed.
+	auth *authenticator
+
+	// limits throttles every route. When it is nil, which is the case
+	// unless a rate limit config is given, nothing is throttled.
+	limits *rateLimits
+
+	// events is where handlers publish album changes.
+	events *broadcaster
+
+	// logger receives one record per request.
+	logger *slog.Logger
+
+	// trustedProxies are the IPs and CIDRs whose X-Forwarded-For header
+	// is believed when working out a request's client IP. When it is
+	// empty, which is the case unless one is configured, the header is
+	// ignored so clients can't pick their own IP.
+	trustedProxies []string
+}
+
+// NewService returns a service backed by store, with auth and rate
+// limiting turned off, that logs requests to slog's default logger.
+func NewService(store Store) *Service {
+	return &Service{
+		store:  store,
+		events: newBroadcaster(eventBufferSize),
+		logger: slog.Default(),
+	}
+}
+
+// Router registers the album routes on a gin engine.
+func (svc *Service) Router() *gin.Engine {
+	// Logging goes ahead of recovery so a panic is logged as the 500 it
+	// turns into.
+	router := gin.New()
+	if err := router.SetTrustedProxies(svc.trustedProxies); err != nil {
+		// Run has already checked the list, so this only trips on a
+		// bad list set in code; trust no one rather than everyone.
+		log.Printf("Ignoring trusted proxies %q: %v", svc.trustedProxies, err)
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(requestIDMiddleware(), loggingMiddleware(svc.logger), gin.Recovery())
+	router.Use(metricsMiddleware(), svc.rateLimitMiddleware())
+	router.GET("/metrics", metricsHandler())
+	router.GET("/openapi.json", openAPIHandler)
+
+	// Requests are checked against the OpenAPI document only once they
+	// are authorized, so its errors can't tell anonymous callers
+	// anything about the schema.
+	validate := openAPIMiddleware(apiSpec)
+
+	router.GET("/albums", validate, svc.getAlbums)
+	router.GET("/albums/export", validate, svc.exportAlbums)
+	router.GET("/albums/search", validate, svc.searchAlbums)
+	router.GET("/albums/events", validate, svc.streamAlbumEvents)
+	router.GET("/albums/:id", validate, svc.getAlbumByID)
+	router.GET("/albums/:id/history", validate, svc.getAlbumHistory)
+
+	// Reads are public; writes need a credential with the right scope.
+	router.POST("/albums", svc.requireScope(scopeWrite), validate, svc.postAlbums)
+	router.POST("/albums:action", svc.requireScope(scopeWrite), validate, svc.albumAction)
+	router.PUT("/albums/:id", svc.requireScope(scopeWrite), validate, svc.putAlbum)
+	router.PATCH("/albums/:id", svc.requireScope(scopeWrite), validate, svc.patchAlbum)
+	router.DELETE("/albums/:id", svc.requireScope(scopeDelete), validate, svc.deleteAlbum)
+	router.POST("/albums/:id/restore", svc.requireScope(scopeDelete), validate, svc.restoreAlbum)
+	return router
+}
+
+// Run opens the store cfg names, serves the album API on cfg.Addr until
+// the process is told to stop, and then closes the store. Everything is
+// logged as JSON to stdout, including output of the standard log
+// package.
+func Run(cfg Config) error {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+	// gin's debug mode prints route tables as plain text; keep it opt-in.
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	store, err := openStore(cfg.Store, cfg.StorePath)
+	if err != nil {
+		return fmt.Errorf("open album store: %w", err)
+	}
+	svc := NewService(store)
+
+	if cfg.AuthConfig != "" {
+		if svc.auth, err = loadAuthenticator(cfg.AuthConfig); err != nil {
+			store.Close()
+			return fmt.Errorf("load auth config: %w", err)
+		}
+		log.Printf("Write routes require credentials from %s", cfg.AuthConfig)
+	} else {
+		log.Println("No auth config given, write routes are open to everyone")
+	}
+
+	if cfg.RateLimitConfig != "" {
+		if svc.limits, err = loadRateLimits(cfg.RateLimitConfig); err != nil {
+			store.Close()
+			return fmt.Errorf("load rate limit config: %w", err)
+		}
+		log.Printf("Rate limiting requests per %s", cfg.RateLimitConfig)
+	}
+
+	if svc.trustedProxies, err = parseTrustedProxies(cfg.TrustedProxies); err != nil {
+		store.Close()
+		return fmt.Errorf("parse trusted proxies: %w", err)
+	}
+
+	err = runServer(cfg, svc.Router(), svc.events.close)
+	if cerr := store.Close(); cerr != nil {
+		log.Printf("Failed to close album store: %v", cerr)
+	}
+	return err
+}
+
+// openStore opens the album backend named by kind: "memory" or "file",
+// which persists to the JSON Lines log at path.
+func openStore(kind, path string) (Store, error) {
+	switch kind {
+	case "memory":
+		log.Println("Using in-memory album store")
+		return newMemoryStore(SeedAlbums), nil
+	case "file":
+		log.Printf("Using file album store at %s", path)
+		return openFileStore(path, SeedAlbums)
+	default:
+		return nil, errors.New("unknown album store " + kind)
+	}
+}
+
+// parseTrustedProxies splits the comma-separated list s into the IPs
+// and CIDRs it names, rejecting anything that is neither.
+func parseTrustedProxies(s string) ([]string, error) {
+	var proxies []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			return nil, fmt.Errorf("%q is not an IP or CIDR", p)
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, nil
+}
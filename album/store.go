@@ -0,0 +1,315 @@
+package album
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+var (
+	// errAlbumNotFound is returned when no album matches the requested ID.
+	errAlbumNotFound = errors.New("album not found")
+	// errAlbumExists is returned when adding an album whose ID is taken.
+	errAlbumExists = errors.New("album already exists")
+	// errVersionMismatch is returned when a write names a stale version.
+	errVersionMismatch = errors.New("album version mismatch")
+	// errAlbumNotDeleted is returned when restoring a live album.
+	errAlbumNotDeleted = errors.New("album is not deleted")
+)
+
+// anyVersion tells Update, Delete and Restore to skip the version check.
+const anyVersion = 0
+
+// Store is the storage backend used by the album handlers. Deletes are
+// soft: a deleted album is hidden from List, Get and Search but keeps
+// its ID and history, and can be restored. Every mutation records an
+// auditEntry naming the actor that made it. The backends live in this
+// package, and Run opens the one Config.Store names.
+type Store interface {
+	// List returns a snapshot of every live album in insertion order.
+	List() ([]Album, error)
+	// Get returns the live album with the given ID or errAlbumNotFound.
+	Get(id string) (Album, error)
+	// Add stores a new album at version 1 and returns it. An empty ID is
+	// replaced with the next server-assigned one; a taken ID, even one
+	// of a deleted album, returns errAlbumExists.
+	Add(a Album, actor string) (Album, error)
+	// Update replaces the live album with the same ID if it is still at
+	// the given version, and returns it with its version bumped. It
+	// returns errAlbumNotFound or errVersionMismatch otherwise.
+	Update(a Album, version int, actor string) (Album, error)
+	// Delete hides the live album with the given ID, bumping its
+	// version, if it is still at the given version, or returns
+	// errAlbumNotFound or errVersionMismatch.
+	Delete(id string, version int, actor string) error
+	// Restore brings back the deleted album with the given ID if it is
+	// still at the given version, and returns it with its version
+	// bumped. It returns errAlbumNotFound, errAlbumNotDeleted or
+	// errVersionMismatch otherwise.
+	Restore(id string, version int, actor string) (Album, error)
+	// History returns every audit entry recorded for the album with the
+	// given ID, oldest first, and whether it is currently deleted.
+	History(id string) ([]auditEntry, bool, error)
+	// Search returns the live albums whose title or artist share a token
+	// with query, best match first, and at most limit of them.
+	Search(query string, limit int) ([]searchHit, error)
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// memoryStore keeps albums in a slice guarded by a RWMutex, so
+// concurrent GETs can share the read lock while POSTs serialize. The
+// index maps each ID to its position in the slice for O(1) lookups,
+// and search is an inverted index over titles and artists. Deleted
+// albums stay in the slice, flagged in deleted, so a restore puts them
+// back where they were.
+type memoryStore struct {
+	mu      sync.RWMutex
+	albums  []Album
+	index   map[string]int
+	deleted map[string]bool
+	history map[string][]auditEntry
+	search  *searchIndex
+	nextID  int
+}
+
+// newMemoryStore returns an in-memory store seeded with the given albums.
+func newMemoryStore(seed []Album) *memoryStore {
+	s := &memoryStore{
+		index:   make(map[string]int),
+		deleted: make(map[string]bool),
+		history: make(map[string][]auditEntry),
+		search:  newSearchIndex(),
+		nextID:  1,
+	}
+	for _, a := range seed {
+		s.Add(a, seedActor)
+	}
+	return s
+}
+
+func (s *memoryStore) List() ([]Album, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Copy so callers can't observe later appends.
+	out := make([]Album, 0, len(s.albums)-len(s.deleted))
+	for _, a := range s.albums {
+		if !s.deleted[a.ID] {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Get(id string) (Album, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i, ok := s.index[id]; ok && !s.deleted[id] {
+		return s.albums[i], nil
+	}
+	return Album{}, errAlbumNotFound
+}
+
+func (s *memoryStore) Add(a Album, actor string) (Album, error) {
+	return s.add(a, changeBy(actor))
+}
+
+func (s *memoryStore) Update(a Album, version int, actor string) (Album, error) {
+	return s.update(a, version, changeBy(actor))
+}
+
+func (s *memoryStore) Delete(id string, version int, actor string) error {
+	return s.delete(id, version, changeBy(actor))
+}
+
+func (s *memoryStore) Restore(id string, version int, actor string) (Album, error) {
+	return s.restore(id, version, changeBy(actor))
+}
+
+// add, update, delete and restore apply a mutation stamped with ch,
+// which lets the file store replay its log with the original actors
+// and times.
+func (s *memoryStore) add(a Album, ch change) (Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID == "" {
+		a.ID = strconv.Itoa(s.nextID)
+	}
+	if err := s.precondition(eventCreated, a.ID, anyVersion); err != nil {
+		return Album{}, err
+	}
+
+	a.Version = 1
+	s.index[a.ID] = len(s.albums)
+	s.albums = append(s.albums, a)
+	s.search.add(a)
+	s.record(a.ID, eventCreated, ch, nil, &a)
+
+	// Keep generated IDs ahead of any numeric ID already in the store,
+	// including ones seeded or replayed from a log, without letting the
+	// generator wrap around to negative IDs.
+	if n, err := strconv.Atoi(a.ID); err == nil && n >= s.nextID && n < math.MaxInt {
+		s.nextID = n + 1
+	}
+	return a, nil
+}
+
+func (s *memoryStore) update(a Album, version int, ch change) (Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.precondition(eventUpdated, a.ID, version); err != nil {
+		return Album{}, err
+	}
+	i := s.index[a.ID]
+	before := s.albums[i]
+	a.Version = before.Version + 1
+	s.search.remove(before)
+	s.search.add(a)
+	s.albums[i] = a
+	s.record(a.ID, eventUpdated, ch, &before, &a)
+	return a, nil
+}
+
+func (s *memoryStore) delete(id string, version int, ch change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.precondition(eventDeleted, id, version); err != nil {
+		return err
+	}
+	i := s.index[id]
+	before := s.albums[i]
+	s.search.remove(before)
+	s.albums[i].Version++
+	s.deleted[id] = true
+	s.record(id, eventDeleted, ch, &before, nil)
+	return nil
+}
+
+func (s *memoryStore) restore(id string, version int, ch change) (Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.precondition(eventRestored, id, version); err != nil {
+		return Album{}, err
+	}
+	i := s.index[id]
+	s.albums[i].Version++
+	a := s.albums[i]
+	s.search.add(a)
+	delete(s.deleted, id)
+	s.record(id, eventRestored, ch, nil, &a)
+	return a, nil
+}
+
+// readd brings back the deleted album with a's ID and replaces it with
+// a, recording a restore followed by an update. It returns
+// errAlbumExists if the album is live and errAlbumNotFound if there is
+// none.
+func (s *memoryStore) readd(a Album, ch change) (Album, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.precondition(eventRestored, a.ID, anyVersion); err != nil {
+		if errors.Is(err, errAlbumNotDeleted) {
+			return Album{}, errAlbumExists
+		}
+		return Album{}, err
+	}
+	i := s.index[a.ID]
+	s.albums[i].Version++
+	restored := s.albums[i]
+	delete(s.deleted, a.ID)
+	s.record(a.ID, eventRestored, ch, nil, &restored)
+
+	a.Version = restored.Version + 1
+	s.albums[i] = a
+	s.search.add(a)
+	s.record(a.ID, eventUpdated, ch, &restored, &a)
+	return a, nil
+}
+
+// check reports whether action on the album with the given ID at
+// version would succeed against the current state.
+func (s *memoryStore) check(action, id string, version int) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.precondition(action, id, version)
+}
+
+// precondition is check for callers that already hold s.mu.
+func (s *memoryStore) precondition(action, id string, version int) error {
+	i, ok := s.index[id]
+	switch {
+	case action == eventCreated && ok:
+		return errAlbumExists
+	case action == eventCreated:
+		return nil
+	case !ok:
+		return errAlbumNotFound
+	case action == eventRestored && !s.deleted[id]:
+		return errAlbumNotDeleted
+	case action != eventRestored && s.deleted[id]:
+		return errAlbumNotFound
+	case version != anyVersion && s.albums[i].Version != version:
+		return errVersionMismatch
+	}
+	return nil
+}
+
+// record appends an audit entry for the album's current version. The
+// caller must hold s.mu.
+func (s *memoryStore) record(id, action string, ch change, before, after *Album) {
+	s.history[id] = append(s.history[id], auditEntry{
+		Version: s.albums[s.index[id]].Version,
+		Action:  action,
+		Actor:   ch.By,
+		Time:    ch.At,
+		Before:  before,
+		After:   after,
+	})
+}
+
+func (s *memoryStore) History(id string) ([]auditEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.index[id]; !ok {
+		return nil, false, errAlbumNotFound
+	}
+	// Copy so callers can't observe later appends.
+	return slices.Clone(s.history[id]), s.deleted[id], nil
+}
+
+func (s *memoryStore) Search(query string, limit int) ([]searchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Equal scores fall back to insertion order.
+	ids := s.search.search(query, func(id string) int { return s.index[id] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	hits := make([]searchHit, len(ids))
+	for i, h := range ids {
+		hits[i] = searchHit{Album: s.albums[s.index[h.id]], Score: h.score}
+	}
+	return hits, nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// peekNextID returns the ID the next Add without one would be given.
+func (s *memoryStore) peekNextID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return strconv.Itoa(s.nextID)
+}
@@ -1,58 +1,116 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"sync"
 	"sync/atomic"
-)
+	"time"
 
-func main() {
+	"example.com/web-service-gin/bench"
+)
 
+// atomicTrial has goroutines goroutines each add 1 to an atomic
+// counter increments times, and returns the time taken and final count.
+func atomicTrial(goroutines, increments int) (time.Duration, uint64) {
 	// Atomic integer counter
 	var ops atomic.Uint64
 
 	var wg sync.WaitGroup
+	start := time.Now()
 
-	// Start 50 goroutines
-	for range 50 {
+	for range goroutines {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			// Each increments 1000 times
-			for range 1000 {
+			for range increments {
 				ops.Add(1)
 			}
 		}()
 	}
 
 	wg.Wait()
+	return time.Since(start), ops.Load()
+}
 
-	fmt.Println("ops:", ops.Load())
-
+// regularTrial is atomicTrial with a plain integer counter, which loses
+// increments to the data race.
+func regularTrial(goroutines, increments int) (time.Duration, uint64) {
 	// Regular integer counter for comparison
 	var regularOps uint64
 
-	var wg2 sync.WaitGroup
+	var wg sync.WaitGroup
+	start := time.Now()
 
-	// Start 50 goroutines for regular counter
-	for range 50 {
-		wg2.Add(1)
+	for range goroutines {
+		wg.Add(1)
 		go func() {
-			defer wg2.Done()
-			// Each increments 1000 times
-			for range 1000 {
+			defer wg.Done()
+			for range increments {
 				regularOps++
 			}
 		}()
 	}
 
-	wg2.Wait()
-	fmt.Println("Regular ops:", regularOps)
+	wg.Wait()
+	return time.Since(start), regularOps
+}
 
-	// Show the comparison
-	fmt.Println("\nExpected value: 50,000")
-	fmt.Printf("Atomic counter accuracy: %d (lost: %d)\n",
-		ops.Load(), 50000-int(ops.Load()))
-	fmt.Printf("Regular counter accuracy: %d (lost: %d)\n",
-		regularOps, 50000-int(regularOps))
+func main() {
+	goroutines := flag.Int("goroutines", 50, "goroutines incrementing the counter")
+	increments := flag.Int("increments", 1000, "increments per goroutine")
+	cfg := bench.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	runner, err := bench.NewRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	expected := *goroutines * *increments
+	fmt.Println("=== Atomic vs Regular Counter ===")
+	fmt.Printf("%d goroutines × %d increments\n\n", *goroutines, *increments)
+
+	var atomicOps, regularOps uint64
+	fmt.Println("1. Atomic counter:")
+	_, atomicRan := runner.Run(bench.Scenario{
+		Name:           "Counter",
+		Implementation: "Atomic",
+		Ops:            expected,
+		Run: func() time.Duration {
+			elapsed, n := atomicTrial(*goroutines, *increments)
+			atomicOps = n
+			return elapsed
+		},
+	})
+
+	fmt.Println("\n2. Regular counter:")
+	_, regularRan := runner.Run(bench.Scenario{
+		Name:           "Counter",
+		Implementation: "Regular",
+		Ops:            expected,
+		Run: func() time.Duration {
+			elapsed, n := regularTrial(*goroutines, *increments)
+			regularOps = n
+			return elapsed
+		},
+	})
+
+	// Show the comparison, from the last trial of each
+	fmt.Printf("\nExpected value: %d\n", expected)
+	if atomicRan {
+		fmt.Printf("Atomic counter accuracy: %d (lost: %d)\n",
+			atomicOps, expected-int(atomicOps))
+	}
+	if regularRan {
+		fmt.Printf("Regular counter accuracy: %d (lost: %d)\n",
+			regularOps, expected-int(regularOps))
+	}
 }
@@ -1,3 +0,0 @@
-module hw3
-
-go 1.23.4
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"runtime"
 	"time"
+
+	"example.com/web-service-gin/bench"
 )
 
 func pingPongSingleThread(iterations int) time.Duration {
@@ -77,46 +81,59 @@ func pingPongMultiThread(iterations int) time.Duration {
 }
 
 func main() {
-	iterations := 1000000 // 1 million ping-pongs
+	iterations := flag.Int("iterations", 1000000, "ping-pong exchanges per trial")
+	cfg := bench.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	runner, err := bench.NewRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	fmt.Println("=== Context Switching Experiment ===")
-	fmt.Printf("Performing %d ping-pong exchanges (2 context switches each)\n", iterations)
-	fmt.Printf("Total context switches: %d\n\n", iterations*2)
+	fmt.Printf("Performing %d ping-pong exchanges (2 context switches each)\n", *iterations)
+	fmt.Printf("Total context switches: %d\n\n", *iterations*2)
 
-	// Warm-up runs to stabilize CPU
-	fmt.Println("Warming up...")
-	pingPongSingleThread(10000)
-	pingPongMultiThread(10000)
+	switches := time.Duration(max(*iterations*2, 1))
 
-	fmt.Println("\n1. SINGLE OS THREAD (GOMAXPROCS=1):")
+	fmt.Println("1. SINGLE OS THREAD (GOMAXPROCS=1):")
 	fmt.Println("   Both goroutines must run on the same thread")
-
-	var singleTotal time.Duration
-	for i := 1; i <= 3; i++ {
-		duration := pingPongSingleThread(iterations)
-		fmt.Printf("   Trial %d: %v\n", i, duration)
-		singleTotal += duration
+	single, singleRan := runner.Run(bench.Scenario{
+		Name:           "PingPong",
+		Implementation: "SingleThread",
+		Ops:            *iterations * 2,
+		Run:            func() time.Duration { return pingPongSingleThread(*iterations) },
+	})
+	singleAvg := single.Stats.Mean
+	switchTimeSingle := singleAvg / switches
+	if singleRan {
+		fmt.Printf("   Per context switch: %v\n\n", switchTimeSingle)
 	}
-	singleAvg := singleTotal / 3
-	switchTimeSingle := singleAvg / time.Duration(iterations*2)
-
-	fmt.Printf("   Average total: %v\n", singleAvg)
-	fmt.Printf("   Per context switch: %v\n\n", switchTimeSingle)
 
 	fmt.Printf("2. MULTIPLE OS THREADS (GOMAXPROCS=%d):\n", runtime.NumCPU())
 	fmt.Println("   Goroutines can run on different threads")
-
-	var multiTotal time.Duration
-	for i := 1; i <= 3; i++ {
-		duration := pingPongMultiThread(iterations)
-		fmt.Printf("   Trial %d: %v\n", i, duration)
-		multiTotal += duration
+	multi, multiRan := runner.Run(bench.Scenario{
+		Name:           "PingPong",
+		Implementation: "MultiThread",
+		Ops:            *iterations * 2,
+		Run:            func() time.Duration { return pingPongMultiThread(*iterations) },
+	})
+	multiAvg := multi.Stats.Mean
+	switchTimeMulti := multiAvg / switches
+	if multiRan {
+		fmt.Printf("   Per context switch: %v\n\n", switchTimeMulti)
 	}
-	multiAvg := multiTotal / 3
-	switchTimeMulti := multiAvg / time.Duration(iterations*2)
 
-	fmt.Printf("   Average total: %v\n", multiAvg)
-	fmt.Printf("   Per context switch: %v\n\n", switchTimeMulti)
+	// The comparison only makes sense with both measured.
+	if !singleRan || !multiRan {
+		return
+	}
 
 	// Analysis
 	fmt.Println("📊 RESULTS ANALYSIS:")
@@ -2,9 +2,13 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"log"
 	"os"
 	"time"
+
+	"example.com/web-service-gin/bench"
 )
 
 func unbufferedWrite(filename string, iterations int) time.Duration {
@@ -57,22 +61,56 @@ func bufferedWrite(filename string, iterations int) time.Duration {
 }
 
 func main() {
-	iterations := 100000
+	iterations := flag.Int("iterations", 100000, "lines written per trial")
+	cfg := bench.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	runner, err := bench.NewRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	fmt.Println("=== File I/O Buffering Experiment ===")
-	fmt.Printf("Writing %d lines to file\n\n", iterations)
+	fmt.Printf("Writing %d lines to file\n\n", *iterations)
+
+	lines := time.Duration(max(*iterations, 1))
 
 	// Test unbuffered writes
 	fmt.Println("1. UNBUFFERED writes (direct to disk each line):")
-	unbufferedTime := unbufferedWrite("unbuffered.txt", iterations)
-	fmt.Printf("   Time: %v\n", unbufferedTime)
-	fmt.Printf("   Per write: %v\n\n", unbufferedTime/time.Duration(iterations))
+	unbuffered, unbufferedRan := runner.Run(bench.Scenario{
+		Name:           "Write",
+		Implementation: "Unbuffered",
+		Ops:            *iterations,
+		Run:            func() time.Duration { return unbufferedWrite("unbuffered.txt", *iterations) },
+	})
+	unbufferedTime := unbuffered.Stats.Mean
+	if unbufferedRan {
+		fmt.Printf("   Per write: %v\n\n", unbufferedTime/lines)
+	}
 
 	// Test buffered writes
 	fmt.Println("2. BUFFERED writes (accumulate in memory, then flush):")
-	bufferedTime := bufferedWrite("buffered.txt", iterations)
-	fmt.Printf("   Time: %v\n", bufferedTime)
-	fmt.Printf("   Per write: %v\n\n", bufferedTime/time.Duration(iterations))
+	buffered, bufferedRan := runner.Run(bench.Scenario{
+		Name:           "Write",
+		Implementation: "Buffered",
+		Ops:            *iterations,
+		Run:            func() time.Duration { return bufferedWrite("buffered.txt", *iterations) },
+	})
+	bufferedTime := buffered.Stats.Mean
+	if bufferedRan {
+		fmt.Printf("   Per write: %v\n\n", bufferedTime/lines)
+	}
+
+	// The comparison only makes sense with both measured.
+	if !unbufferedRan || !bufferedRan {
+		return
+	}
 
 	// Calculate speedup
 	speedup := float64(unbufferedTime) / float64(bufferedTime)
@@ -1,104 +1,65 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"sync"
+	"log"
+	"strings"
 	"time"
-)
-
-// SafeMap wraps a map with a mutex for thread-safe access
-type SafeMap struct {
-	mu sync.Mutex
-	m  map[int]int
-}
-
-// Set safely writes a key-value pair
-func (sm *SafeMap) Set(key, value int) {
-	sm.mu.Lock()         // Lock before modifying
-	defer sm.mu.Unlock() // Unlock when function returns
-	sm.m[key] = value
-}
-
-// Get safely retrieves a value
-func (sm *SafeMap) Get(key int) (int, bool) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	val, ok := sm.m[key]
-	return val, ok
-}
 
-// Len safely returns the map length
-func (sm *SafeMap) Len() int {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	return len(sm.m)
-}
+	"example.com/web-service-gin/bench"
+	"example.com/web-service-gin/cmap"
+	"example.com/web-service-gin/workload"
+)
 
 func main() {
-	fmt.Println("=== Mutex-Protected Map with Reads and Writes ===")
-	fmt.Println("25 writer goroutines × 1000 writes = 25,000 writes")
-	fmt.Println("25 reader goroutines × 2000 reads = 50,000 reads")
-	fmt.Println("Total operations: 75,000\n")
-
-	// Run the experiment 3 times and calculate mean
-	var totalTime time.Duration
-
-	for trial := 1; trial <= 3; trial++ {
-		fmt.Printf("Trial %d: ", trial)
-
-		// Create a new SafeMap
-		safeMap := &SafeMap{
-			m: make(map[int]int),
-		}
-
-		var wg sync.WaitGroup
-		start := time.Now()
-
-		// First, populate some initial data
-		for i := 0; i < 1000; i++ {
-			safeMap.Set(i, i*10)
-		}
-
-		// Spawn 25 WRITER goroutines
-		for g := 0; g < 25; g++ {
-			wg.Add(1)
-			go func(goroutineID int) {
-				defer wg.Done()
-				// Each writes 1000 entries
-				for i := 0; i < 1000; i++ {
-					key := goroutineID*1000 + i
-					safeMap.Set(key, i) // Thread-safe WRITE operation
-				}
-			}(g)
-		}
-
-		// Spawn 25 READER goroutines
-		for g := 0; g < 25; g++ {
-			wg.Add(1)
-			go func(goroutineID int) {
-				defer wg.Done()
-				successfulReads := 0
-				for i := 0; i < 2000; i++ {
-					// Try to read various keys
-					key := (goroutineID*100 + i) % 25000
-					if _, ok := safeMap.Get(key); ok { // READ operation
-						successfulReads++
-					}
-				}
-				// Optionally track successful reads
-			}(g)
+	writers := flag.Int("writers", 25, "writer goroutines")
+	writes := flag.Int("writes", 1000, "writes per writer goroutine")
+	readers := flag.Int("readers", 25, "reader goroutines")
+	reads := flag.Int("reads", 2000, "reads per reader goroutine")
+	impl := flag.String("map", "Mutex", "map implementation: "+strings.Join(cmap.Names, ", "))
+	cfg := bench.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	newMap, err := cmap.Constructor[int, int](*impl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner, err := bench.NewRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			log.Fatal(err)
 		}
-
-		wg.Wait()
-		elapsed := time.Since(start)
-		totalTime += elapsed
-
-		mapLen := safeMap.Len() // Final READ operation
-		fmt.Printf("Final map length: %d, Time: %v\n", mapLen, elapsed)
+	}()
+
+	total := *writers**writes + *readers**reads
+	fmt.Printf("=== %s Map with Reads and Writes ===\n", *impl)
+	fmt.Printf("%d writer goroutines × %d writes = %d writes\n", *writers, *writes, *writers**writes)
+	fmt.Printf("%d reader goroutines × %d reads = %d reads\n", *readers, *reads, *readers**reads)
+	fmt.Printf("Total operations: %d\n\n", total)
+
+	var mapLen int
+	res, ok := runner.Run(bench.Scenario{
+		Name:           "ReadWrite",
+		Implementation: *impl,
+		Ops:            total,
+		Run: func() time.Duration {
+			elapsed, n := workload.ReadWrite(newMap(), *writers, *writes, *readers, *reads)
+			mapLen = n
+			return elapsed
+		},
+	})
+	if !ok {
+		return
 	}
 
-	// Calculate and print mean
-	meanTime := totalTime / 3
-	fmt.Printf("\nMean time for 75,000 operations: %v\n", meanTime)
-	fmt.Printf("Mutex ensures thread-safety for BOTH reads and writes!\n")
+	fmt.Printf("\nFinal map length: %d\n", mapLen)
+	fmt.Printf("Mean time for %d operations with %s: %v\n", total, *impl, res.Stats.Mean)
+	if *impl == "Mutex" {
+		fmt.Printf("Mutex ensures thread-safety for BOTH reads and writes!\n")
+	}
 }
@@ -1,94 +1,65 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"sync"
+	"log"
+	"strings"
 	"time"
-)
-
-// RWMap wraps a map with a RWMutex
-type RWMap struct {
-	mu sync.RWMutex // RWMutex instead of Mutex
-	m  map[int]int
-}
-
-// Set uses write lock for writing
-func (rwm *RWMap) Set(key, value int) {
-	rwm.mu.Lock() // Exclusive write lock
-	defer rwm.mu.Unlock()
-	rwm.m[key] = value
-}
-
-// Get uses read lock for reading
-func (rwm *RWMap) Get(key int) (int, bool) {
-	rwm.mu.RLock() // Shared read lock - multiple readers allowed!
-	defer rwm.mu.RUnlock()
-	val, ok := rwm.m[key]
-	return val, ok
-}
 
-// Len uses read lock since it only reads
-func (rwm *RWMap) Len() int {
-	rwm.mu.RLock()
-	defer rwm.mu.RUnlock()
-	return len(rwm.m)
-}
+	"example.com/web-service-gin/bench"
+	"example.com/web-service-gin/cmap"
+	"example.com/web-service-gin/workload"
+)
 
 func main() {
-	fmt.Println("=== RWMutex Map with Reads and Writes ===")
-	fmt.Println("25 writer goroutines × 1000 writes = 25,000 writes")
-	fmt.Println("25 reader goroutines × 2000 reads = 50,000 reads")
-	fmt.Println("Total operations: 75,000\n")
-
-	var totalTime time.Duration
-
-	for trial := 1; trial <= 3; trial++ {
-		fmt.Printf("Trial %d: ", trial)
-
-		rwMap := &RWMap{
-			m: make(map[int]int),
-		}
-
-		var wg sync.WaitGroup
-		start := time.Now()
-
-		// Initial data
-		for i := 0; i < 1000; i++ {
-			rwMap.Set(i, i*10)
-		}
-
-		// 25 WRITER goroutines
-		for g := 0; g < 25; g++ {
-			wg.Add(1)
-			go func(goroutineID int) {
-				defer wg.Done()
-				for i := 0; i < 1000; i++ {
-					key := goroutineID*1000 + i
-					rwMap.Set(key, i) // Write lock - exclusive
-				}
-			}(g)
-		}
-
-		// 25 READER goroutines
-		for g := 0; g < 25; g++ {
-			wg.Add(1)
-			go func(goroutineID int) {
-				defer wg.Done()
-				for i := 0; i < 2000; i++ {
-					key := (goroutineID*100 + i) % 25000
-					rwMap.Get(key) // Read lock - can be shared!
-				}
-			}(g)
+	writers := flag.Int("writers", 25, "writer goroutines")
+	writes := flag.Int("writes", 1000, "writes per writer goroutine")
+	readers := flag.Int("readers", 25, "reader goroutines")
+	reads := flag.Int("reads", 2000, "reads per reader goroutine")
+	impl := flag.String("map", "RWMutex", "map implementation: "+strings.Join(cmap.Names, ", "))
+	cfg := bench.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	newMap, err := cmap.Constructor[int, int](*impl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner, err := bench.NewRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			log.Fatal(err)
 		}
-
-		wg.Wait()
-		elapsed := time.Since(start)
-		totalTime += elapsed
-
-		mapLen := rwMap.Len()
-		fmt.Printf("Final map length: %d, Time: %v\n", mapLen, elapsed)
+	}()
+
+	total := *writers**writes + *readers**reads
+	fmt.Printf("=== %s Map with Reads and Writes ===\n", *impl)
+	fmt.Printf("%d writer goroutines × %d writes = %d writes\n", *writers, *writes, *writers**writes)
+	fmt.Printf("%d reader goroutines × %d reads = %d reads\n", *readers, *reads, *readers**reads)
+	fmt.Printf("Total operations: %d\n\n", total)
+
+	var mapLen int
+	res, ok := runner.Run(bench.Scenario{
+		Name:           "ReadWrite",
+		Implementation: *impl,
+		Ops:            total,
+		Run: func() time.Duration {
+			elapsed, n := workload.ReadWrite(newMap(), *writers, *writes, *readers, *reads)
+			mapLen = n
+			return elapsed
+		},
+	})
+	if !ok {
+		return
 	}
 
-	fmt.Printf("\nMean time for 75,000 operations: %v\n", totalTime/3)
-	fmt.Println("RWMutex allows multiple concurrent readers while still protecting against writes!")
+	fmt.Printf("\nFinal map length: %d\n", mapLen)
+	fmt.Printf("Mean time for %d operations with %s: %v\n", total, *impl, res.Stats.Mean)
+	if *impl == "RWMutex" {
+		fmt.Println("RWMutex allows multiple concurrent readers while still protecting against writes!")
+	}
 }
@@ -1,343 +1,199 @@
 package main
 
 import (
+	"encoding/binary"
+	"flag"
 	"fmt"
+	"hash/fnv"
+	"log"
 	"strings"
-	"sync"
 	"time"
-)
 
-// Test structures for each approach
+	"example.com/web-service-gin/bench"
+	"example.com/web-service-gin/cmap"
+	"example.com/web-service-gin/workload"
+)
 
-type MutexMap struct {
-	mu sync.Mutex
-	m  map[int]int
+// Preset workloads, named for the -workloads flag. Balanced and
+// Read-Heavy are the two original scenarios; custom is built from the
+// mix flags.
+var presets = map[string]workload.Mix{
+	"balanced":   {Name: "Balanced", ReadRatio: 0.5, Keys: 26000, Ops: 1000},
+	"read-heavy": {Name: "Read-Heavy", ReadRatio: 0.9, Keys: 5000, Ops: 500},
 }
 
-type RWMutexMap struct {
-	mu sync.RWMutex
-	m  map[int]int
+// contender is one map implementation under comparison.
+type contender struct {
+	name   string
+	newMap func() cmap.ConcurrentMap[int, int]
 }
 
-// Test 1: Balanced Read/Write (50/50)
-func testMutexBalanced() time.Duration {
-	mm := &MutexMap{m: make(map[int]int)}
-	var wg sync.WaitGroup
-
-	// Pre-populate
-	for i := 0; i < 1000; i++ {
-		mm.m[i] = i
-	}
-
-	start := time.Now()
-
-	// 25 writers, 25 readers
-	for w := 0; w < 25; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 1000; i++ {
-				mm.mu.Lock()
-				mm.m[id*1000+i] = i
-				mm.mu.Unlock()
-			}
-		}(w)
-	}
-
-	for r := 0; r < 25; r++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 1000; i++ {
-				mm.mu.Lock()
-				_ = mm.m[i%26000]
-				mm.mu.Unlock()
-			}
-		}(r)
-	}
-
-	wg.Wait()
-	return time.Since(start)
+// Hash functions the sharded map can stripe by. maphash scatters keys
+// pseudo-randomly; identity puts consecutive keys in consecutive shards.
+var hashes = map[string]func(int) uint64{
+	"maphash": nil, // NewShardedMap's default
+	"fnv": func(key int) uint64 {
+		h := fnv.New64a()
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(key))
+		h.Write(b[:])
+		return h.Sum64()
+	},
+	"identity": func(key int) uint64 { return uint64(key) },
 }
 
-func testRWMutexBalanced() time.Duration {
-	rwm := &RWMutexMap{m: make(map[int]int)}
-	var wg sync.WaitGroup
-
-	// Pre-populate
-	for i := 0; i < 1000; i++ {
-		rwm.m[i] = i
-	}
-
-	start := time.Now()
-
-	// 25 writers
-	for w := 0; w < 25; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 1000; i++ {
-				rwm.mu.Lock()
-				rwm.m[id*1000+i] = i
-				rwm.mu.Unlock()
-			}
-		}(w)
-	}
-
-	// 25 readers
-	for r := 0; r < 25; r++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 1000; i++ {
-				rwm.mu.RLock()
-				_ = rwm.m[i%26000]
-				rwm.mu.RUnlock()
-			}
-		}(r)
-	}
-
-	wg.Wait()
-	return time.Since(start)
+// result is one contender's mean time in a scenario.
+type result struct {
+	name string
+	mean time.Duration
 }
 
-func testSyncMapBalanced() time.Duration {
-	var m sync.Map
-	var wg sync.WaitGroup
-
-	// Pre-populate
-	for i := 0; i < 1000; i++ {
-		m.Store(i, i)
-	}
-
-	start := time.Now()
-
-	// 25 writers
-	for w := 0; w < 25; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 1000; i++ {
-				m.Store(id*1000+i, i)
-			}
-		}(w)
+// fastest returns the result with the lowest mean.
+func fastest(results []result) result {
+	best := results[0]
+	for _, r := range results[1:] {
+		if r.mean < best.mean {
+			best = r
+		}
 	}
-
-	// 25 readers
-	for r := 0; r < 25; r++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 1000; i++ {
-				m.Load(i % 26000)
-			}
-		}(r)
-	}
-
-	wg.Wait()
-	return time.Since(start)
+	return best
 }
 
-// Test 2: Read-Heavy (90% reads, 10% writes)
-func testMutexReadHeavy() time.Duration {
-	mm := &MutexMap{m: make(map[int]int)}
-	var wg sync.WaitGroup
-
-	// Pre-populate
-	for i := 0; i < 5000; i++ {
-		mm.m[i] = i
-	}
-
-	start := time.Now()
-
-	// 5 writers (500 writes each)
-	for w := 0; w < 5; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 500; i++ {
-				mm.mu.Lock()
-				mm.m[id*1000+i] = i
-				mm.mu.Unlock()
-			}
-		}(w)
-	}
-
-	// 45 readers (500 reads each)
-	for r := 0; r < 45; r++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 500; i++ {
-				mm.mu.Lock()
-				_ = mm.m[i%5000]
-				mm.mu.Unlock()
-			}
-		}(r)
-	}
-
-	wg.Wait()
-	return time.Since(start)
+// ms formats d as fractional milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
 }
 
-func testRWMutexReadHeavy() time.Duration {
-	rwm := &RWMutexMap{m: make(map[int]int)}
-	var wg sync.WaitGroup
-
-	// Pre-populate
-	for i := 0; i < 5000; i++ {
-		rwm.m[i] = i
-	}
-
-	start := time.Now()
-
-	// 5 writers
-	for w := 0; w < 5; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 500; i++ {
-				rwm.mu.Lock()
-				rwm.m[id*1000+i] = i
-				rwm.mu.Unlock()
-			}
-		}(w)
-	}
-
-	// 45 readers
-	for r := 0; r < 45; r++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 500; i++ {
-				rwm.mu.RLock()
-				_ = rwm.m[i%5000]
-				rwm.mu.RUnlock()
-			}
-		}(r)
-	}
-
-	wg.Wait()
-	return time.Since(start)
-}
-
-func testSyncMapReadHeavy() time.Duration {
-	var m sync.Map
-	var wg sync.WaitGroup
-
-	// Pre-populate
-	for i := 0; i < 5000; i++ {
-		m.Store(i, i)
-	}
-
-	start := time.Now()
-
-	// 5 writers
-	for w := 0; w < 5; w++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 500; i++ {
-				m.Store(id*1000+i, i)
-			}
-		}(w)
-	}
-
-	// 45 readers
-	for r := 0; r < 45; r++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			for i := 0; i < 500; i++ {
-				m.Load(i % 5000)
-			}
-		}(r)
-	}
-
-	wg.Wait()
-	return time.Since(start)
-}
-
-func runBenchmark(name string, fn func() time.Duration) time.Duration {
-	var total time.Duration
-	for i := 0; i < 3; i++ {
-		duration := fn()
-		fmt.Printf("      Trial %d: %v\n", i+1, duration)
-		total += duration
-	}
-	avg := total / 3
-	fmt.Printf("      Average: %v\n", avg)
-	return avg
+// runMix times each contender under mix with the shared harness, every
+// trial replaying the same operations on a fresh map, and returns the
+// means of those that ran.
+func runMix(runner *bench.Runner, mix workload.Mix, contenders []contender, seed uint64) []result {
+	var results []result
+	for i, c := range contenders {
+		scenario := bench.Scenario{
+			Name:           mix.Name,
+			Implementation: c.name,
+			Ops:            mix.Goroutines * mix.Ops,
+			Run:            func() time.Duration { return workload.Run(c.newMap(), mix, seed) },
+		}
+		if !runner.Selected(scenario.String()) {
+			continue
+		}
+		fmt.Printf("\n  %d. %s:\n", i+1, c.name)
+		res, ok := runner.Run(scenario)
+		if ok {
+			results = append(results, result{c.name, res.Stats.Mean})
+		}
+	}
+	return results
 }
 
 func main() {
-	fmt.Println("=== Comprehensive Map Synchronization Comparison ===\n")
-
-	// Test 1: Balanced workload
-	fmt.Println("SCENARIO 1: Balanced Read/Write (50/50)")
-	fmt.Println("25 writers (1000 writes each) + 25 readers (1000 reads each)")
-	fmt.Println("Total: 25,000 writes + 25,000 reads = 50,000 operations\n")
-
-	fmt.Println("  1. Mutex:")
-	mutexBalanced := runBenchmark("Mutex Balanced", testMutexBalanced)
-
-	fmt.Println("\n  2. RWMutex:")
-	rwMutexBalanced := runBenchmark("RWMutex Balanced", testRWMutexBalanced)
-
-	fmt.Println("\n  3. sync.Map:")
-	syncMapBalanced := runBenchmark("sync.Map Balanced", testSyncMapBalanced)
-
-	// Test 2: Read-heavy workload
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("\nSCENARIO 2: Read-Heavy (90% reads, 10% writes)")
-	fmt.Println("5 writers (500 writes each) + 45 readers (500 reads each)")
-	fmt.Println("Total: 2,500 writes + 22,500 reads = 25,000 operations\n")
-
-	fmt.Println("  1. Mutex:")
-	mutexReadHeavy := runBenchmark("Mutex Read-Heavy", testMutexReadHeavy)
-
-	fmt.Println("\n  2. RWMutex:")
-	rwMutexReadHeavy := runBenchmark("RWMutex Read-Heavy", testRWMutexReadHeavy)
-
-	fmt.Println("\n  3. sync.Map:")
-	syncMapReadHeavy := runBenchmark("sync.Map Read-Heavy", testSyncMapReadHeavy)
+	names := flag.String("workloads", "balanced,read-heavy", "comma-separated workloads to run: balanced, read-heavy, custom")
+	goroutines := flag.Int("goroutines", 50, "goroutines per workload, each running the whole mix")
+	dist := flag.String("dist", "uniform", "key distribution: uniform, zipfian or hotspot")
+	readRatio := flag.Float64("read-ratio", 0.5, "share of reads in the custom workload")
+	deleteRatio := flag.Float64("delete-ratio", 0, "share of deletes in the custom workload")
+	keys := flag.Int("keys", 10000, "key-space size of the custom workload")
+	ops := flag.Int("ops", 1000, "operations per goroutine in the custom workload")
+	seed := flag.Uint64("seed", 1, "seed for the generated operations")
+	countLen := flag.Bool("count-len", false, "have sync.Map keep a running length like the other maps, at a cost to every write")
+	shards := flag.Int("shards", cmap.DefaultShards, "shard count for the sharded map")
+	hashName := flag.String("hash", "maphash", "hash the sharded map stripes keys by: maphash, fnv or identity")
+	cfg := bench.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	hash, ok := hashes[*hashName]
+	if !ok {
+		log.Fatalf("unknown hash %q (want maphash, fnv or identity)", *hashName)
+	}
+	distribution, err := workload.ParseDistribution(*dist)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var mixes []workload.Mix
+	for _, name := range strings.Split(*names, ",") {
+		name = strings.TrimSpace(name)
+		mix, ok := presets[name]
+		if name == "custom" {
+			mix, ok = workload.Mix{Name: "Custom", ReadRatio: *readRatio, DeleteRatio: *deleteRatio, Keys: *keys, Ops: *ops}, true
+		}
+		if !ok {
+			log.Fatalf("unknown workload %q (want balanced, read-heavy or custom)", name)
+		}
+		mix.Distribution = distribution
+		mix.Goroutines = *goroutines
+		if err := mix.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		mixes = append(mixes, mix)
+	}
+
+	runner, err := bench.NewRunner(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// Left to itself sync.Map doesn't track its length, so by default
+	// it's measured without the counter the adapter can keep.
+	syncMap := contender{"sync.Map", func() cmap.ConcurrentMap[int, int] { return cmap.NewUncountedSyncMap[int, int]() }}
+	if *countLen {
+		syncMap = contender{"sync.Map+Len", func() cmap.ConcurrentMap[int, int] { return cmap.NewSyncMap[int, int]() }}
+	}
+	contenders := []contender{
+		{"Mutex", func() cmap.ConcurrentMap[int, int] { return cmap.NewMutexMap[int, int]() }},
+		{"RWMutex", func() cmap.ConcurrentMap[int, int] { return cmap.NewRWMutexMap[int, int]() }},
+		syncMap,
+		{"Sharded", func() cmap.ConcurrentMap[int, int] { return cmap.NewShardedMap[int, int](*shards, hash) }},
+	}
+
+	fmt.Print("=== Comprehensive Map Synchronization Comparison ===\n")
+	fmt.Printf("Sharded map: %d shards, %s hash\n", *shards, *hashName)
+	if *countLen {
+		fmt.Println("sync.Map+Len: sync.Map plus the adapter's shared length counter")
+	}
+
+	results := make([][]result, len(mixes))
+	for i, mix := range mixes {
+		if i > 0 {
+			fmt.Println("\n" + strings.Repeat("=", 50))
+		}
+		fmt.Printf("\nSCENARIO %d: %s (%s)\n", i+1, mix.Name, mix)
+		fmt.Printf("%d goroutines × %d operations over %d %s keys\n",
+			mix.Goroutines, mix.Ops, mix.Keys, mix.Distribution)
+		fmt.Printf("Total: %d operations\n", mix.Goroutines*mix.Ops)
+		results[i] = runMix(runner, mix, contenders, *seed)
+	}
 
 	// Summary
 	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("\n📊 PERFORMANCE SUMMARY\n")
-
-	fmt.Println("Balanced Workload (50% reads, 50% writes):")
-	fmt.Printf("  🥇 Winner: ")
-	if rwMutexBalanced < mutexBalanced && rwMutexBalanced < syncMapBalanced {
-		fmt.Printf("RWMutex (%.2fms)\n", float64(rwMutexBalanced.Microseconds())/1000)
-	} else if mutexBalanced < syncMapBalanced {
-		fmt.Printf("Mutex (%.2fms)\n", float64(mutexBalanced.Microseconds())/1000)
-	} else {
-		fmt.Printf("sync.Map (%.2fms)\n", float64(syncMapBalanced.Microseconds())/1000)
-	}
-	fmt.Printf("  - Mutex:     %.2fms\n", float64(mutexBalanced.Microseconds())/1000)
-	fmt.Printf("  - RWMutex:   %.2fms\n", float64(rwMutexBalanced.Microseconds())/1000)
-	fmt.Printf("  - sync.Map:  %.2fms\n", float64(syncMapBalanced.Microseconds())/1000)
-
-	fmt.Println("\nRead-Heavy Workload (90% reads, 10% writes):")
-	fmt.Printf("  🥇 Winner: ")
-	if rwMutexReadHeavy < mutexReadHeavy && rwMutexReadHeavy < syncMapReadHeavy {
-		fmt.Printf("RWMutex (%.2fms)\n", float64(rwMutexReadHeavy.Microseconds())/1000)
-	} else if syncMapReadHeavy < mutexReadHeavy {
-		fmt.Printf("sync.Map (%.2fms)\n", float64(syncMapReadHeavy.Microseconds())/1000)
-	} else {
-		fmt.Printf("Mutex (%.2fms)\n", float64(mutexReadHeavy.Microseconds())/1000)
+	fmt.Print("\n📊 PERFORMANCE SUMMARY\n")
+
+	for i, mix := range mixes {
+		if len(results[i]) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s Workload (%s, %s keys):\n", mix.Name, mix, mix.Distribution)
+		winner := fastest(results[i])
+		fmt.Printf("  🥇 Winner: %s (%.2fms)\n", winner.name, ms(winner.mean))
+		baseline := results[i][0]
+		fmt.Printf("  - %-14s %.2fms (baseline)\n", baseline.name+":", ms(baseline.mean))
+		for _, r := range results[i][1:] {
+			fmt.Printf("  - %-14s %.2fms (%.1f%% improvement)\n", r.name+":", ms(r.mean),
+				float64(baseline.mean-r.mean)/float64(baseline.mean)*100)
+		}
 	}
-	fmt.Printf("  - Mutex:     %.2fms (baseline)\n", float64(mutexReadHeavy.Microseconds())/1000)
-	fmt.Printf("  - RWMutex:   %.2fms (%.1f%% improvement)\n",
-		float64(rwMutexReadHeavy.Microseconds())/1000,
-		float64(mutexReadHeavy-rwMutexReadHeavy)/float64(mutexReadHeavy)*100)
-	fmt.Printf("  - sync.Map:  %.2fms (%.1f%% improvement)\n",
-		float64(syncMapReadHeavy.Microseconds())/1000,
-		float64(mutexReadHeavy-syncMapReadHeavy)/float64(mutexReadHeavy)*100)
 
 	fmt.Println("\n📝 KEY INSIGHTS:")
 	fmt.Println("• Mutex: Consistent but forces all operations to serialize")
 	fmt.Println("• RWMutex: Excels when reads can happen concurrently")
 	fmt.Println("• sync.Map: Best for stable keys with rare updates")
+	fmt.Println("• Sharded: Splits the lock so writers only contend within a shard")
 }
@@ -0,0 +1,153 @@
+// Package bench runs the hw3 concurrency experiments as named
+// scenarios: each one is warmed up, timed over a number of trials and
+// summarized, with the trial settings taken from command-line flags.
+// Trials can also be written to JSON and CSV files for reports.
+package bench
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Scenario is one timed experiment. Run performs a single trial and
+// returns how long the measured part of it took, leaving setup out.
+type Scenario struct {
+	Name string
+	// Implementation, if set, is which contender this is when several
+	// run the same scenario.
+	Implementation string
+	// Ops is how many operations a trial performs, for the ops/sec
+	// column of the results files. Zero leaves it out.
+	Ops int
+	Run func() time.Duration
+}
+
+// String returns the name the -run filter matches and the output
+// prints: Name, then Implementation after a slash if set.
+func (s Scenario) String() string {
+	if s.Implementation == "" {
+		return s.Name
+	}
+	return s.Name + "/" + s.Implementation
+}
+
+// Result is what running a scenario produced.
+type Result struct {
+	Scenario  string
+	Durations []time.Duration
+	Stats     Stats
+}
+
+// Config controls how scenarios are run.
+type Config struct {
+	WarmUp int
+	Trials int
+	// Filter, when set, limits the run to scenarios whose name it
+	// matches.
+	Filter string
+	Out    io.Writer
+	// JSON and CSV, when set, name files to write every trial to.
+	JSON string
+	CSV  string
+}
+
+// DefaultConfig matches what the experiments did before they shared a
+// harness: three trials, plus one warm-up.
+func DefaultConfig() Config {
+	return Config{WarmUp: 1, Trials: 3, Out: os.Stdout}
+}
+
+// RegisterFlags defines -warmup, -trials, -run, -json and -csv on fs,
+// defaulting to the current values of cfg.
+func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&cfg.WarmUp, "warmup", cfg.WarmUp, "untimed trials before measuring each scenario")
+	fs.IntVar(&cfg.Trials, "trials", cfg.Trials, "timed trials per scenario")
+	fs.StringVar(&cfg.Filter, "run", cfg.Filter, "only run scenarios whose name matches this regexp")
+	fs.StringVar(&cfg.JSON, "json", cfg.JSON, "write every trial to this file as JSON")
+	fs.StringVar(&cfg.CSV, "csv", cfg.CSV, "write every trial to this file as CSV")
+}
+
+// Runner runs scenarios one after another with a shared Config. Call
+// Close when done to finish the results files.
+type Runner struct {
+	cfg    Config
+	filter *regexp.Regexp
+	out    *output
+	err    error // first failure writing the results files
+}
+
+// NewRunner checks cfg, creates any results files it names and returns
+// a Runner for it.
+func NewRunner(cfg Config) (*Runner, error) {
+	if cfg.Trials < 1 {
+		return nil, fmt.Errorf("bench: need at least one trial, got %d", cfg.Trials)
+	}
+	if cfg.WarmUp < 0 {
+		return nil, fmt.Errorf("bench: warm-up can't be negative, got %d", cfg.WarmUp)
+	}
+	if cfg.Out == nil {
+		cfg.Out = os.Stdout
+	}
+	r := &Runner{cfg: cfg}
+	if cfg.Filter != "" {
+		re, err := regexp.Compile(cfg.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("bench: -run: %w", err)
+		}
+		r.filter = re
+	}
+	out, err := openOutput(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.out = out
+	return r, nil
+}
+
+// Selected reports whether the filter lets the named scenario run.
+func (r *Runner) Selected(name string) bool {
+	return r.filter == nil || r.filter.MatchString(name)
+}
+
+// Run warms up and times s, printing each trial and the summary, and
+// reports ok=false if the filter skipped it.
+func (r *Runner) Run(s Scenario) (res Result, ok bool) {
+	if !r.Selected(s.String()) {
+		return Result{}, false
+	}
+
+	for range r.cfg.WarmUp {
+		s.Run()
+	}
+
+	res = Result{Scenario: s.String(), Durations: make([]time.Duration, 0, r.cfg.Trials)}
+	for i := 1; i <= r.cfg.Trials; i++ {
+		d := s.Run()
+		fmt.Fprintf(r.cfg.Out, "      Trial %d: %v\n", i, d)
+		res.Durations = append(res.Durations, d)
+		if err := r.out.record(s, i, d.Nanoseconds()); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	res.Stats = Summarize(res.Durations)
+	r.printStats(res.Stats)
+	return res, true
+}
+
+func (r *Runner) printStats(st Stats) {
+	fmt.Fprintf(r.cfg.Out, "      Mean: %v  Median: %v  StdDev: %v  p95: %v\n", st.Mean, st.Median, st.StdDev, st.P95)
+	if st.Trials > 1 {
+		fmt.Fprintf(r.cfg.Out, "      95%% CI of mean: [%v, %v]\n", st.CILow, st.CIHigh)
+	}
+}
+
+// Close writes the JSON results file and closes the results files,
+// reporting the first error writing either of them.
+func (r *Runner) Close() error {
+	return errors.Join(r.err, r.out.close())
+}
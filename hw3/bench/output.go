@@ -0,0 +1,137 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// Record is one timed trial as written to the -json and -csv files.
+type Record struct {
+	Scenario       string  `json:"scenario"`
+	Implementation string  `json:"implementation,omitempty"`
+	Trial          int     `json:"trial"`
+	DurationNS     int64   `json:"duration_ns"`
+	OpsPerSec      float64 `json:"ops_per_sec,omitempty"`
+	GOMAXPROCS     int     `json:"gomaxprocs"`
+	GoVersion      string  `json:"go_version"`
+	NumCPU         int     `json:"num_cpu"`
+}
+
+// csvHeader names the CSV columns, in Record field order.
+var csvHeader = []string{
+	"scenario", "implementation", "trial", "duration_ns", "ops_per_sec",
+	"gomaxprocs", "go_version", "num_cpu",
+}
+
+func (rec Record) csvRow() []string {
+	ops := ""
+	if rec.OpsPerSec > 0 {
+		ops = strconv.FormatFloat(rec.OpsPerSec, 'f', 2, 64)
+	}
+	return []string{
+		rec.Scenario,
+		rec.Implementation,
+		strconv.Itoa(rec.Trial),
+		strconv.FormatInt(rec.DurationNS, 10),
+		ops,
+		strconv.Itoa(rec.GOMAXPROCS),
+		rec.GoVersion,
+		strconv.Itoa(rec.NumCPU),
+	}
+}
+
+// output collects trial records for the files named in a Config. CSV
+// rows are written as trials finish, so an interrupted run keeps what
+// it measured; the JSON array can only be written once all are in.
+type output struct {
+	records  []Record
+	jsonFile *os.File
+	csvFile  *os.File
+	csv      *csv.Writer
+}
+
+// openOutput creates the files cfg names, truncating any that exist.
+func openOutput(cfg Config) (*output, error) {
+	out := &output{}
+	if cfg.JSON != "" {
+		f, err := os.Create(cfg.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("bench: -json: %w", err)
+		}
+		out.jsonFile = f
+	}
+	if cfg.CSV != "" {
+		f, err := os.Create(cfg.CSV)
+		if err != nil {
+			out.close()
+			return nil, fmt.Errorf("bench: -csv: %w", err)
+		}
+		out.csvFile = f
+		out.csv = csv.NewWriter(f)
+		if err := out.csv.Write(csvHeader); err != nil {
+			out.close()
+			return nil, fmt.Errorf("bench: -csv: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// record adds the trial of s that took ns nanoseconds under the current
+// GOMAXPROCS, which scenarios may have changed themselves.
+func (out *output) record(s Scenario, trial int, ns int64) error {
+	rec := Record{
+		Scenario:       s.Name,
+		Implementation: s.Implementation,
+		Trial:          trial,
+		DurationNS:     ns,
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		GoVersion:      runtime.Version(),
+		NumCPU:         runtime.NumCPU(),
+	}
+	if s.Ops > 0 && ns > 0 {
+		rec.OpsPerSec = float64(s.Ops) / (float64(ns) / 1e9)
+	}
+	out.records = append(out.records, rec)
+
+	if out.csv == nil {
+		return nil
+	}
+	out.csv.Write(rec.csvRow())
+	out.csv.Flush()
+	if err := out.csv.Error(); err != nil {
+		return fmt.Errorf("bench: -csv: %w", err)
+	}
+	return nil
+}
+
+// close writes the JSON file and closes both files.
+func (out *output) close() error {
+	var errs []error
+	if out.jsonFile != nil {
+		enc := json.NewEncoder(out.jsonFile)
+		enc.SetIndent("", "  ")
+		records := out.records
+		if records == nil {
+			records = []Record{}
+		}
+		if err := enc.Encode(records); err != nil {
+			errs = append(errs, fmt.Errorf("bench: -json: %w", err))
+		}
+		if err := out.jsonFile.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("bench: -json: %w", err))
+		}
+		out.jsonFile = nil
+	}
+	if out.csvFile != nil {
+		if err := out.csvFile.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("bench: -csv: %w", err))
+		}
+		out.csvFile = nil
+	}
+	return errors.Join(errs...)
+}
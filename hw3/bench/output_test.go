@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestResultsFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		Trials: 2,
+		Out:    io.Discard,
+		JSON:   filepath.Join(dir, "results.json"),
+		CSV:    filepath.Join(dir, "results.csv"),
+	}
+	runner, err := NewRunner(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.Run(Scenario{Name: "Write", Implementation: "Buffered", Ops: 1000,
+		Run: func() time.Duration { return time.Millisecond }})
+	runner.Run(Scenario{Name: "Untimed", Run: func() time.Duration { return time.Second }})
+	if err := runner.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(cfg.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("results.json: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d JSON records, want 4", len(records))
+	}
+	want := Record{
+		Scenario: "Write", Implementation: "Buffered", Trial: 2,
+		DurationNS: int64(time.Millisecond), OpsPerSec: 1e6,
+		GOMAXPROCS: runtime.GOMAXPROCS(0), GoVersion: runtime.Version(), NumCPU: runtime.NumCPU(),
+	}
+	if records[1] != want {
+		t.Errorf("record 1 = %+v, want %+v", records[1], want)
+	}
+	if records[3].OpsPerSec != 0 {
+		t.Errorf("scenario without Ops got ops/sec %v", records[3].OpsPerSec)
+	}
+
+	f, err := os.Open(cfg.CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("results.csv: %v", err)
+	}
+	if len(rows) != 5 || rows[0][0] != "scenario" {
+		t.Fatalf("results.csv has %d rows starting %v, want a header and 4 trials", len(rows), rows[0])
+	}
+	if got := rows[1][:5]; got[0] != "Write" || got[1] != "Buffered" || got[2] != "1" || got[3] != "1000000" || got[4] != "1000000.00" {
+		t.Errorf("first CSV row = %v", rows[1])
+	}
+}
+
+func TestScenarioString(t *testing.T) {
+	if got := (Scenario{Name: "Balanced", Implementation: "Mutex"}).String(); got != "Balanced/Mutex" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Scenario{Name: "Counter"}).String(); got != "Counter" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Stats summarizes the trial durations of one scenario.
+type Stats struct {
+	Trials int
+	Mean   time.Duration
+	Median time.Duration
+	StdDev time.Duration
+	P95    time.Duration
+	// CILow and CIHigh bound the 95% confidence interval of the mean,
+	// from Student's t distribution since trial counts are small.
+	CILow  time.Duration
+	CIHigh time.Duration
+}
+
+// Summarize computes Stats over durations, which it doesn't modify.
+func Summarize(durations []time.Duration) Stats {
+	n := len(durations)
+	if n == 0 {
+		return Stats{}
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, d := range sorted {
+		sum += float64(d)
+	}
+	mean := sum / float64(n)
+
+	var sd float64
+	if n > 1 {
+		var sq float64
+		for _, d := range sorted {
+			sq += (float64(d) - mean) * (float64(d) - mean)
+		}
+		sd = math.Sqrt(sq / float64(n-1))
+	}
+	margin := tCritical95(n-1) * sd / math.Sqrt(float64(n))
+
+	return Stats{
+		Trials: n,
+		Mean:   time.Duration(mean),
+		Median: median(sorted),
+		StdDev: time.Duration(sd),
+		P95:    percentile(sorted, 95),
+		CILow:  time.Duration(mean - margin),
+		CIHigh: time.Duration(mean + margin),
+	}
+}
+
+// median returns the middle of sorted, averaging the two middle values
+// when there is an even number of them.
+func median(sorted []time.Duration) time.Duration {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// percentile returns the nearest-rank pth percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// tTable holds the two-sided 95% critical values of Student's t
+// distribution for 1 to 30 degrees of freedom.
+var tTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tCritical95 returns the t value for a 95% interval with df degrees of
+// freedom, using the normal approximation past the table. With no
+// degrees of freedom there is no spread to scale, so it returns 0.
+func tCritical95(df int) float64 {
+	switch {
+	case df < 1:
+		return 0
+	case df <= len(tTable):
+		return tTable[df-1]
+	default:
+		return 1.96
+	}
+}
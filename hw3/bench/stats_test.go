@@ -0,0 +1,36 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	ms := time.Millisecond
+	durations := []time.Duration{4 * ms, 1 * ms, 3 * ms, 2 * ms, 5 * ms}
+	st := Summarize(durations)
+
+	if st.Trials != 5 || st.Mean != 3*ms || st.Median != 3*ms || st.P95 != 5*ms {
+		t.Errorf("Summarize = %+v, want 5 trials, mean 3ms, median 3ms, p95 5ms", st)
+	}
+	// Sample stddev of 1..5 is sqrt(2.5) ≈ 1.581.
+	if st.StdDev < 1581*time.Microsecond || st.StdDev > 1582*time.Microsecond {
+		t.Errorf("StdDev = %v, want ≈1.581ms", st.StdDev)
+	}
+	if st.CILow >= st.Mean || st.CIHigh <= st.Mean {
+		t.Errorf("CI [%v, %v] doesn't bracket mean %v", st.CILow, st.CIHigh, st.Mean)
+	}
+	if durations[0] != 4*ms {
+		t.Errorf("Summarize reordered its input: %v", durations)
+	}
+}
+
+func TestSummarizeSingleTrial(t *testing.T) {
+	st := Summarize([]time.Duration{time.Second})
+	if st.Mean != time.Second || st.StdDev != 0 || st.CILow != time.Second || st.CIHigh != time.Second {
+		t.Errorf("Summarize(1s) = %+v", st)
+	}
+	if st := Summarize(nil); st != (Stats{}) {
+		t.Errorf("Summarize(nil) = %+v, want zero", st)
+	}
+}
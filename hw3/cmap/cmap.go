@@ -0,0 +1,78 @@
+// Package cmap provides interchangeable concurrency-safe maps, so the
+// hw3 experiments can run the same workload against a single Mutex, a
+// single RWMutex, sync.Map and a sharded map.
+package cmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConcurrentMap is a map that is safe for use by multiple goroutines.
+type ConcurrentMap[K comparable, V any] interface {
+	// Get returns the value stored under key and whether it was present.
+	Get(key K) (V, bool)
+	// Set stores value under key.
+	Set(key K, value V)
+	// Delete removes key, if present.
+	Delete(key K)
+	// Len returns the number of keys in the map.
+	Len() int
+	// Range calls f for each key and value until f returns false. Like
+	// sync.Map.Range, it doesn't see a single consistent snapshot, and f
+	// may modify the map.
+	Range(f func(key K, value V) bool)
+	// LoadOrStore returns the existing value for key if present and
+	// reports true. Otherwise it stores value and returns it with false.
+	LoadOrStore(key K, value V) (actual V, loaded bool)
+	// CompareAndSwap stores new under key if its current value equals
+	// old, and reports whether it did. As with sync.Map it panics if V
+	// values aren't comparable.
+	CompareAndSwap(key K, old, new V) bool
+}
+
+// Names lists the implementations New accepts, in the order the
+// experiments compare them.
+var Names = []string{"Mutex", "RWMutex", "sync.Map", "sync.Map-uncounted", "Sharded"}
+
+// New returns an empty map of the implementation called name, matched
+// case-insensitively against Names.
+func New[K comparable, V any](name string) (ConcurrentMap[K, V], error) {
+	newMap, err := Constructor[K, V](name)
+	if err != nil {
+		return nil, err
+	}
+	return newMap(), nil
+}
+
+// Constructor is New for callers that need a fresh map per run: it
+// checks name once and returns a function making empty maps of that
+// implementation.
+func Constructor[K comparable, V any](name string) (func() ConcurrentMap[K, V], error) {
+	switch strings.ToLower(name) {
+	case "mutex":
+		return func() ConcurrentMap[K, V] { return NewMutexMap[K, V]() }, nil
+	case "rwmutex":
+		return func() ConcurrentMap[K, V] { return NewRWMutexMap[K, V]() }, nil
+	case "sync.map", "syncmap":
+		return func() ConcurrentMap[K, V] { return NewSyncMap[K, V]() }, nil
+	case "sync.map-uncounted", "syncmap-uncounted":
+		return func() ConcurrentMap[K, V] { return NewUncountedSyncMap[K, V]() }, nil
+	case "sharded":
+		return func() ConcurrentMap[K, V] { return NewShardedMap[K, V](DefaultShards, nil) }, nil
+	}
+	return nil, fmt.Errorf("unknown map implementation %q (want one of %s)", name, strings.Join(Names, ", "))
+}
+
+// equal reports whether a and b are equal, panicking like == on
+// interfaces if V isn't comparable.
+func equal[V any](a, b V) bool {
+	return any(a) == any(b)
+}
+
+// entry is one key and value copied out of a locked map, so Range can
+// call back without holding the lock.
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
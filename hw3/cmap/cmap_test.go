@@ -0,0 +1,166 @@
+package cmap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestImplementations(t *testing.T) {
+	for _, name := range Names {
+		t.Run(name, func(t *testing.T) {
+			m, err := New[string, int](name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := m.Get("a"); ok {
+				t.Error("Get on empty map found a")
+			}
+			m.Set("a", 1)
+			m.Set("b", 2)
+			m.Set("a", 3)
+			if v, ok := m.Get("a"); !ok || v != 3 {
+				t.Errorf("Get(a) = %d, %v, want 3, true", v, ok)
+			}
+			if n := m.Len(); n != 2 {
+				t.Errorf("Len = %d, want 2", n)
+			}
+
+			if v, loaded := m.LoadOrStore("a", 9); !loaded || v != 3 {
+				t.Errorf("LoadOrStore(a) = %d, %v, want 3, true", v, loaded)
+			}
+			if v, loaded := m.LoadOrStore("c", 4); loaded || v != 4 {
+				t.Errorf("LoadOrStore(c) = %d, %v, want 4, false", v, loaded)
+			}
+
+			if m.CompareAndSwap("b", 9, 5) {
+				t.Error("CompareAndSwap(b, 9, 5) swapped a mismatched value")
+			}
+			if !m.CompareAndSwap("b", 2, 5) {
+				t.Error("CompareAndSwap(b, 2, 5) didn't swap")
+			}
+			if m.CompareAndSwap("z", 0, 1) {
+				t.Error("CompareAndSwap on a missing key swapped")
+			}
+
+			m.Delete("c")
+			m.Delete("missing")
+			got := map[string]int{}
+			m.Range(func(k string, v int) bool {
+				got[k] = v
+				return true
+			})
+			if len(got) != 2 || got["a"] != 3 || got["b"] != 5 || m.Len() != 2 {
+				t.Errorf("after Delete, Range saw %v and Len = %d, want map[a:3 b:5]", got, m.Len())
+			}
+
+			calls := 0
+			m.Range(func(string, int) bool {
+				calls++
+				return false
+			})
+			if calls != 1 {
+				t.Errorf("Range kept going after f returned false: %d calls", calls)
+			}
+
+			// A nil interface is a value like any other.
+			nils, err := New[string, error](name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nils.Set("a", nil)
+			if v, ok := nils.Get("a"); !ok || v != nil {
+				t.Errorf("Get(a) = %v, %v, want nil, true", v, ok)
+			}
+			if v, loaded := nils.LoadOrStore("a", nil); !loaded || v != nil {
+				t.Errorf("LoadOrStore(a) = %v, %v, want nil, true", v, loaded)
+			}
+			if v, loaded := nils.LoadOrStore("b", nil); loaded || v != nil {
+				t.Errorf("LoadOrStore(b) = %v, %v, want nil, false", v, loaded)
+			}
+			if !nils.CompareAndSwap("a", nil, nil) {
+				t.Error("CompareAndSwap(a, nil, nil) didn't swap")
+			}
+			nils.Range(func(k string, v error) bool {
+				if v != nil {
+					t.Errorf("Range saw %s = %v, want nil", k, v)
+				}
+				return true
+			})
+			if n := nils.Len(); n != 2 {
+				t.Errorf("Len = %d, want 2", n)
+			}
+		})
+	}
+}
+
+func TestConcurrentLoadOrStore(t *testing.T) {
+	for _, name := range Names {
+		t.Run(name, func(t *testing.T) {
+			m, err := New[int, int](name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every goroutine races to store the same keys; each key
+			// must end up stored exactly once.
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			stored := 0
+			for g := range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range 1000 {
+						if _, loaded := m.LoadOrStore(i, g); !loaded {
+							mu.Lock()
+							stored++
+							mu.Unlock()
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if stored != 1000 || m.Len() != 1000 {
+				t.Errorf("stored %d keys with Len %d, want 1000", stored, m.Len())
+			}
+		})
+	}
+}
+
+func TestShardedMapHash(t *testing.T) {
+	// Every key in shard 0 still has to behave like a map, just a
+	// contended one.
+	m := NewShardedMap[int, int](4, func(int) uint64 { return 0 })
+	for i := range 100 {
+		m.Set(i, i)
+	}
+	if n := len(m.shards[0].m); n != 100 {
+		t.Errorf("shard 0 holds %d keys, want all 100", n)
+	}
+	if v, ok := m.Get(42); !ok || v != 42 || m.Len() != 100 {
+		t.Errorf("Get(42) = %d, %v with Len %d", v, ok, m.Len())
+	}
+
+	identity := NewShardedMap[int, int](4, func(k int) uint64 { return uint64(k) })
+	for i := range 100 {
+		identity.Set(i, i)
+	}
+	for i := range identity.shards {
+		if n := len(identity.shards[i].m); n != 25 {
+			t.Errorf("shard %d holds %d keys, want 25", i, n)
+		}
+	}
+
+	if n := len(NewShardedMap[int, int](0, nil).shards); n != DefaultShards {
+		t.Errorf("NewShardedMap(0) made %d shards, want %d", n, DefaultShards)
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	if _, err := New[int, int]("btree"); err == nil {
+		t.Error("New(btree) succeeded")
+	}
+}
@@ -0,0 +1,75 @@
+package cmap
+
+import "sync"
+
+// MutexMap guards a plain map with a single Mutex, so every operation,
+// reads included, is serialized.
+type MutexMap[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]V
+}
+
+// NewMutexMap returns an empty MutexMap.
+func NewMutexMap[K comparable, V any]() *MutexMap[K, V] {
+	return &MutexMap[K, V]{m: make(map[K]V)}
+}
+
+func (mm *MutexMap[K, V]) Get(key K) (V, bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	val, ok := mm.m[key]
+	return val, ok
+}
+
+func (mm *MutexMap[K, V]) Set(key K, value V) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.m[key] = value
+}
+
+func (mm *MutexMap[K, V]) Delete(key K) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	delete(mm.m, key)
+}
+
+func (mm *MutexMap[K, V]) Len() int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return len(mm.m)
+}
+
+func (mm *MutexMap[K, V]) Range(f func(key K, value V) bool) {
+	mm.mu.Lock()
+	entries := make([]entry[K, V], 0, len(mm.m))
+	for k, v := range mm.m {
+		entries = append(entries, entry[K, V]{k, v})
+	}
+	mm.mu.Unlock()
+
+	for _, e := range entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
+
+func (mm *MutexMap[K, V]) LoadOrStore(key K, value V) (V, bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if val, ok := mm.m[key]; ok {
+		return val, true
+	}
+	mm.m[key] = value
+	return value, false
+}
+
+func (mm *MutexMap[K, V]) CompareAndSwap(key K, old, new V) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if val, ok := mm.m[key]; !ok || !equal(val, old) {
+		return false
+	}
+	mm.m[key] = new
+	return true
+}
@@ -0,0 +1,84 @@
+package cmap
+
+import "sync"
+
+// RWMutexMap guards a plain map with a single RWMutex, so reads can run
+// concurrently with each other but not with writes.
+type RWMutexMap[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+// NewRWMutexMap returns an empty RWMutexMap.
+func NewRWMutexMap[K comparable, V any]() *RWMutexMap[K, V] {
+	return &RWMutexMap[K, V]{m: make(map[K]V)}
+}
+
+func (rwm *RWMutexMap[K, V]) Get(key K) (V, bool) {
+	rwm.mu.RLock()
+	defer rwm.mu.RUnlock()
+	val, ok := rwm.m[key]
+	return val, ok
+}
+
+func (rwm *RWMutexMap[K, V]) Set(key K, value V) {
+	rwm.mu.Lock()
+	defer rwm.mu.Unlock()
+	rwm.m[key] = value
+}
+
+func (rwm *RWMutexMap[K, V]) Delete(key K) {
+	rwm.mu.Lock()
+	defer rwm.mu.Unlock()
+	delete(rwm.m, key)
+}
+
+func (rwm *RWMutexMap[K, V]) Len() int {
+	rwm.mu.RLock()
+	defer rwm.mu.RUnlock()
+	return len(rwm.m)
+}
+
+func (rwm *RWMutexMap[K, V]) Range(f func(key K, value V) bool) {
+	rwm.mu.RLock()
+	entries := make([]entry[K, V], 0, len(rwm.m))
+	for k, v := range rwm.m {
+		entries = append(entries, entry[K, V]{k, v})
+	}
+	rwm.mu.RUnlock()
+
+	for _, e := range entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
+
+func (rwm *RWMutexMap[K, V]) LoadOrStore(key K, value V) (V, bool) {
+	// Most calls in a warm map find the key, so try under the read lock
+	// first.
+	rwm.mu.RLock()
+	val, ok := rwm.m[key]
+	rwm.mu.RUnlock()
+	if ok {
+		return val, true
+	}
+
+	rwm.mu.Lock()
+	defer rwm.mu.Unlock()
+	if val, ok := rwm.m[key]; ok {
+		return val, true
+	}
+	rwm.m[key] = value
+	return value, false
+}
+
+func (rwm *RWMutexMap[K, V]) CompareAndSwap(key K, old, new V) bool {
+	rwm.mu.Lock()
+	defer rwm.mu.Unlock()
+	if val, ok := rwm.m[key]; !ok || !equal(val, old) {
+		return false
+	}
+	rwm.m[key] = new
+	return true
+}
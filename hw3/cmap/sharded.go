@@ -0,0 +1,124 @@
+package cmap
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// DefaultShards is the shard count New uses for the sharded map.
+const DefaultShards = 32
+
+// ShardedMap spreads keys by hash over several shards, each a plain map
+// behind its own RWMutex, so goroutines touching different shards never
+// contend for a lock.
+type ShardedMap[K comparable, V any] struct {
+	hash   func(K) uint64
+	shards []shard[K, V]
+}
+
+// shard is one lock-striped part of a ShardedMap.
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+// NewShardedMap returns an empty ShardedMap with the given number of
+// shards, or DefaultShards if shards isn't positive. Keys go to shard
+// hash(key) % shards; a nil hash uses maphash with a random seed.
+func NewShardedMap[K comparable, V any](shards int, hash func(K) uint64) *ShardedMap[K, V] {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	if hash == nil {
+		seed := maphash.MakeSeed()
+		hash = func(key K) uint64 { return maphash.Comparable(seed, key) }
+	}
+	sm := &ShardedMap[K, V]{
+		hash:   hash,
+		shards: make([]shard[K, V], shards),
+	}
+	for i := range sm.shards {
+		sm.shards[i].m = make(map[K]V)
+	}
+	return sm
+}
+
+// shard returns the shard that holds key.
+func (sm *ShardedMap[K, V]) shard(key K) *shard[K, V] {
+	return &sm.shards[sm.hash(key)%uint64(len(sm.shards))]
+}
+
+func (sm *ShardedMap[K, V]) Get(key K) (V, bool) {
+	s := sm.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	val, ok := s.m[key]
+	return val, ok
+}
+
+func (sm *ShardedMap[K, V]) Set(key K, value V) {
+	s := sm.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = value
+}
+
+func (sm *ShardedMap[K, V]) Delete(key K) {
+	s := sm.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, key)
+}
+
+// Len sums the shard lengths one shard at a time, so under concurrent
+// writes it may match no single moment.
+func (sm *ShardedMap[K, V]) Len() int {
+	n := 0
+	for i := range sm.shards {
+		s := &sm.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func (sm *ShardedMap[K, V]) Range(f func(key K, value V) bool) {
+	for i := range sm.shards {
+		s := &sm.shards[i]
+		s.mu.RLock()
+		entries := make([]entry[K, V], 0, len(s.m))
+		for k, v := range s.m {
+			entries = append(entries, entry[K, V]{k, v})
+		}
+		s.mu.RUnlock()
+
+		for _, e := range entries {
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+func (sm *ShardedMap[K, V]) LoadOrStore(key K, value V) (V, bool) {
+	s := sm.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if val, ok := s.m[key]; ok {
+		return val, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+func (sm *ShardedMap[K, V]) CompareAndSwap(key K, old, new V) bool {
+	s := sm.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if val, ok := s.m[key]; !ok || !equal(val, old) {
+		return false
+	}
+	s.m[key] = new
+	return true
+}
@@ -0,0 +1,87 @@
+package cmap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SyncMap adapts sync.Map to ConcurrentMap. sync.Map has no length, so
+// SyncMap either counts keys as they're added and removed, which costs
+// every write a Swap and an update to one shared counter, or walks the
+// whole map in Len.
+type SyncMap[K comparable, V any] struct {
+	m       sync.Map
+	counted bool
+	n       atomic.Int64
+}
+
+// NewSyncMap returns an empty SyncMap with a constant-time Len.
+func NewSyncMap[K comparable, V any]() *SyncMap[K, V] {
+	return &SyncMap[K, V]{counted: true}
+}
+
+// NewUncountedSyncMap returns an empty SyncMap whose writes cost what
+// sync.Map's own do, at the price of a Len that walks every key.
+func NewUncountedSyncMap[K comparable, V any]() *SyncMap[K, V] {
+	return &SyncMap[K, V]{}
+}
+
+func (sm *SyncMap[K, V]) Get(key K) (V, bool) {
+	val, ok := sm.m.Load(key)
+	// A stored nil interface comes back as a nil any, which the
+	// comma-ok assertion turns back into V's zero value.
+	v, _ := val.(V)
+	return v, ok
+}
+
+func (sm *SyncMap[K, V]) Set(key K, value V) {
+	if !sm.counted {
+		sm.m.Store(key, value)
+		return
+	}
+	if _, loaded := sm.m.Swap(key, value); !loaded {
+		sm.n.Add(1)
+	}
+}
+
+func (sm *SyncMap[K, V]) Delete(key K) {
+	if !sm.counted {
+		sm.m.Delete(key)
+		return
+	}
+	if _, loaded := sm.m.LoadAndDelete(key); loaded {
+		sm.n.Add(-1)
+	}
+}
+
+func (sm *SyncMap[K, V]) Len() int {
+	if sm.counted {
+		return int(sm.n.Load())
+	}
+	n := 0
+	sm.m.Range(func(any, any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func (sm *SyncMap[K, V]) Range(f func(key K, value V) bool) {
+	sm.m.Range(func(k, v any) bool {
+		val, _ := v.(V)
+		return f(k.(K), val)
+	})
+}
+
+func (sm *SyncMap[K, V]) LoadOrStore(key K, value V) (V, bool) {
+	val, loaded := sm.m.LoadOrStore(key, value)
+	if !loaded && sm.counted {
+		sm.n.Add(1)
+	}
+	v, _ := val.(V)
+	return v, loaded
+}
+
+func (sm *SyncMap[K, V]) CompareAndSwap(key K, old, new V) bool {
+	return sm.m.CompareAndSwap(key, old, new)
+}
@@ -0,0 +1,228 @@
+// Package workload generates mixed read/write/delete operations over a
+// key space and replays them against a cmap.ConcurrentMap, so the map
+// comparison can describe a scenario by its mix instead of hand-writing
+// a goroutine loop per scenario and implementation.
+package workload
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/web-service-gin/cmap"
+)
+
+// Distribution is how operations pick their keys.
+type Distribution string
+
+const (
+	// Uniform picks every key with equal probability.
+	Uniform Distribution = "uniform"
+	// Zipfian picks key k with probability proportional to
+	// 1/(k+1)^ZipfExponent, so a few low keys take most operations.
+	Zipfian Distribution = "zipfian"
+	// Hotspot sends HotspotShare of operations to the first
+	// HotspotFraction of the key space and the rest uniformly.
+	Hotspot Distribution = "hotspot"
+)
+
+// Shape parameters of the skewed distributions.
+const (
+	ZipfExponent    = 1.1
+	HotspotFraction = 0.1
+	HotspotShare    = 0.9
+)
+
+// Distributions lists the supported distributions.
+var Distributions = []Distribution{Uniform, Zipfian, Hotspot}
+
+// Mix describes a workload. Each operation is a read with probability
+// ReadRatio, a delete with probability DeleteRatio and a write
+// otherwise.
+type Mix struct {
+	Name         string
+	ReadRatio    float64
+	DeleteRatio  float64
+	Keys         int // size of the key space, keys 0..Keys-1
+	Distribution Distribution
+	Goroutines   int
+	Ops          int // operations per goroutine
+}
+
+// Validate reports whether m can be run.
+func (m Mix) Validate() error {
+	switch {
+	case m.ReadRatio < 0 || m.DeleteRatio < 0 || m.ReadRatio+m.DeleteRatio > 1:
+		return fmt.Errorf("workload %s: read ratio %g and delete ratio %g must be non-negative and sum to at most 1",
+			m.Name, m.ReadRatio, m.DeleteRatio)
+	case m.Keys < 1:
+		return fmt.Errorf("workload %s: need at least one key, got %d", m.Name, m.Keys)
+	case m.Goroutines < 1:
+		return fmt.Errorf("workload %s: need at least one goroutine, got %d", m.Name, m.Goroutines)
+	case m.Ops < 0:
+		return fmt.Errorf("workload %s: operations per goroutine can't be negative, got %d", m.Name, m.Ops)
+	}
+	if _, err := ParseDistribution(string(m.Distribution)); err != nil {
+		return fmt.Errorf("workload %s: %w", m.Name, err)
+	}
+	return nil
+}
+
+// WriteRatio is the share of operations that are writes.
+func (m Mix) WriteRatio() float64 {
+	return 1 - m.ReadRatio - m.DeleteRatio
+}
+
+// String describes the mix the way the experiment headers print it.
+func (m Mix) String() string {
+	s := fmt.Sprintf("%.0f%% reads, %.0f%% writes", m.ReadRatio*100, m.WriteRatio()*100)
+	if m.DeleteRatio > 0 {
+		s += fmt.Sprintf(", %.0f%% deletes", m.DeleteRatio*100)
+	}
+	return s
+}
+
+// ParseDistribution returns the distribution called name.
+func ParseDistribution(name string) (Distribution, error) {
+	for _, d := range Distributions {
+		if strings.EqualFold(name, string(d)) {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown key distribution %q (want uniform, zipfian or hotspot)", name)
+}
+
+// Kind is what an operation does to the map.
+type Kind uint8
+
+const (
+	Read Kind = iota
+	Write
+	Delete
+)
+
+// Op is one generated operation.
+type Op struct {
+	Kind Kind
+	Key  int
+}
+
+// Generate returns the operations goroutine g runs under m. The same m,
+// g and seed always give the same operations.
+func (m Mix) Generate(g int, seed uint64) []Op {
+	r := rand.New(rand.NewPCG(seed, uint64(g)))
+	key := m.keyPicker(r)
+
+	ops := make([]Op, m.Ops)
+	for i := range ops {
+		kind := Write
+		switch p := r.Float64(); {
+		case p < m.ReadRatio:
+			kind = Read
+		case p < m.ReadRatio+m.DeleteRatio:
+			kind = Delete
+		}
+		ops[i] = Op{Kind: kind, Key: key()}
+	}
+	return ops
+}
+
+// keyPicker returns a function drawing keys from m's distribution.
+func (m Mix) keyPicker(r *rand.Rand) func() int {
+	switch m.Distribution {
+	case Zipfian:
+		z := rand.NewZipf(r, ZipfExponent, 1, uint64(m.Keys-1))
+		return func() int { return int(z.Uint64()) }
+	case Hotspot:
+		hot := max(int(float64(m.Keys)*HotspotFraction), 1)
+		return func() int {
+			if r.Float64() < HotspotShare {
+				return r.IntN(hot)
+			}
+			return r.IntN(m.Keys)
+		}
+	}
+	return func() int { return r.IntN(m.Keys) }
+}
+
+// Run fills target with every key in m's key space, generates each
+// goroutine's operations, then times the goroutines replaying them
+// concurrently. Setup and generation aren't timed.
+func Run(target cmap.ConcurrentMap[int, int], m Mix, seed uint64) time.Duration {
+	// Pre-populate
+	for k := 0; k < m.Keys; k++ {
+		target.Set(k, k)
+	}
+
+	plans := make([][]Op, m.Goroutines)
+	for g := range plans {
+		plans[g] = m.Generate(g, seed)
+	}
+
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for _, ops := range plans {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, op := range ops {
+				switch op.Kind {
+				case Read:
+					target.Get(op.Key)
+				case Write:
+					target.Set(op.Key, i)
+				case Delete:
+					target.Delete(op.Key)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return time.Since(start)
+}
+
+// ReadWrite is the fixed read/write trial of the Mutex and RWMutex
+// experiments. It seeds target with 1000 entries, then times writers
+// goroutines each setting their own range of writes keys alongside
+// readers goroutines each reading reads keys, and returns the time
+// taken and target's final size.
+func ReadWrite(target cmap.ConcurrentMap[int, int], writers, writes, readers, reads int) (time.Duration, int) {
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	// Initial data
+	for i := 0; i < 1000; i++ {
+		target.Set(i, i*10)
+	}
+
+	// WRITER goroutines
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(goroutineID int) {
+			defer wg.Done()
+			// Each writes its own range of keys
+			for i := 0; i < writes; i++ {
+				target.Set(goroutineID*writes+i, i)
+			}
+		}(g)
+	}
+
+	// READER goroutines
+	keySpace := max(writers*writes, 1)
+	for g := 0; g < readers; g++ {
+		wg.Add(1)
+		go func(goroutineID int) {
+			defer wg.Done()
+			for i := 0; i < reads; i++ {
+				target.Get((goroutineID*100 + i) % keySpace)
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	return time.Since(start), target.Len()
+}
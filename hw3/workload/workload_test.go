@@ -0,0 +1,103 @@
+package workload
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"example.com/web-service-gin/cmap"
+)
+
+func TestGenerateRatios(t *testing.T) {
+	m := Mix{Name: "test", ReadRatio: 0.7, DeleteRatio: 0.1, Keys: 1000,
+		Distribution: Uniform, Goroutines: 1, Ops: 100000}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[Kind]int{}
+	for _, op := range m.Generate(0, 1) {
+		counts[op.Kind]++
+		if op.Key < 0 || op.Key >= m.Keys {
+			t.Fatalf("key %d outside [0, %d)", op.Key, m.Keys)
+		}
+	}
+	for kind, want := range map[Kind]float64{Read: 0.7, Delete: 0.1, Write: 0.2} {
+		if got := float64(counts[kind]) / float64(m.Ops); math.Abs(got-want) > 0.01 {
+			t.Errorf("kind %d made up %.3f of operations, want %.1f", kind, got, want)
+		}
+	}
+
+	if !slices.Equal(m.Generate(3, 7), m.Generate(3, 7)) {
+		t.Error("Generate isn't deterministic for a fixed goroutine and seed")
+	}
+}
+
+func TestDistributionsSkew(t *testing.T) {
+	// Share of operations landing on the lowest tenth of the keys.
+	hotShare := func(d Distribution) float64 {
+		m := Mix{Name: string(d), ReadRatio: 1, Keys: 1000, Distribution: d, Goroutines: 1, Ops: 50000}
+		hot := 0
+		for _, op := range m.Generate(0, 1) {
+			if op.Key < 100 {
+				hot++
+			}
+		}
+		return float64(hot) / float64(m.Ops)
+	}
+
+	if got := hotShare(Uniform); math.Abs(got-0.1) > 0.01 {
+		t.Errorf("uniform sent %.3f of operations to the lowest 10%% of keys, want 0.1", got)
+	}
+	// 90% targeted plus a tenth of the uniform remainder.
+	if got := hotShare(Hotspot); math.Abs(got-0.91) > 0.01 {
+		t.Errorf("hotspot sent %.3f of operations to the hot keys, want 0.91", got)
+	}
+	if got := hotShare(Zipfian); got < 0.5 {
+		t.Errorf("zipfian sent only %.3f of operations to the lowest 10%% of keys", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Mix{Name: "ok", ReadRatio: 0.5, Keys: 10, Distribution: Uniform, Goroutines: 1, Ops: 1}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate(%+v) = %v", valid, err)
+	}
+	for _, bad := range []func(*Mix){
+		func(m *Mix) { m.ReadRatio, m.DeleteRatio = 0.8, 0.3 },
+		func(m *Mix) { m.ReadRatio = -0.1 },
+		func(m *Mix) { m.Keys = 0 },
+		func(m *Mix) { m.Goroutines = 0 },
+		func(m *Mix) { m.Distribution = "pareto" },
+	} {
+		m := valid
+		bad(&m)
+		if err := m.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", m)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	m := Mix{Name: "writes", Keys: 100, Distribution: Hotspot, Goroutines: 4, Ops: 1000}
+	target := cmap.NewShardedMap[int, int](4, nil)
+	if d := Run(target, m, 1); d <= 0 {
+		t.Errorf("Run took %v", d)
+	}
+	// Writes only overwrite pre-populated keys.
+	if n := target.Len(); n != m.Keys {
+		t.Errorf("Len = %d after a write-only run, want %d", n, m.Keys)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	target := cmap.NewRWMutexMap[int, int]()
+	d, n := ReadWrite(target, 4, 500, 4, 100)
+	if d <= 0 {
+		t.Errorf("ReadWrite took %v", d)
+	}
+	// The writers' 2000 keys cover the 1000 seeded ones.
+	if n != 2000 || target.Len() != 2000 {
+		t.Errorf("final length = %d, map Len = %d; want 2000", n, target.Len())
+	}
+}